@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -70,6 +71,38 @@ func bbApiRangedGet(endpoint string, dataRange string) []byte {
 	return body
 }
 
+/* Retrieves every page of a paginated endpoint and returns all the values */
+func bbApiGetAllPages[T any](endpoint string) []T {
+	var values []T
+	for endpoint != "" {
+		var paginatedResponse BBPaginatedResponse[T]
+		response := bbApiGet(endpoint)
+		err := json.Unmarshal(response, &paginatedResponse)
+		cobra.CheckErr(err)
+		values = append(values, paginatedResponse.Values...)
+		endpoint = strings.Replace(paginatedResponse.Next, viper.GetString("bb_api")+"/", "", 1)
+	}
+	return values
+}
+
+/* Same as bbApiGetAllPages but returns the error of any request instead of exiting */
+func bbApiRequestAllPages[T any](endpoint string) ([]T, error) {
+	var values []T
+	for endpoint != "" {
+		var paginatedResponse BBPaginatedResponse[T]
+		response, err := bbApiRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(response, &paginatedResponse); err != nil {
+			return nil, err
+		}
+		values = append(values, paginatedResponse.Values...)
+		endpoint = strings.Replace(paginatedResponse.Next, viper.GetString("bb_api")+"/", "", 1)
+	}
+	return values, nil
+}
+
 func bbApiDownloadFile(endpoint string, filepath string) error {
 	url := fmt.Sprintf("%s/%s", viper.GetString("bb_api"), endpoint)
 
@@ -96,28 +129,63 @@ func bbApiDownloadFile(endpoint string, filepath string) error {
 }
 
 func _bbApiPostPut(method string, endpoint string, body io.Reader) []byte {
+	responseBody, err := bbApiRequest(method, endpoint, body)
+	cobra.CheckErr(err)
+	return responseBody
+}
+
+/* Sends a request and returns the error message from bitbucket instead of exiting when it fails */
+func bbApiRequest(method string, endpoint string, body io.Reader) ([]byte, error) {
 	client := &http.Client{}
 	url := fmt.Sprintf("%s/%s", viper.GetString("bb_api"), endpoint)
 
 	req, err := http.NewRequest(method, url, body)
-	cobra.CheckErr(err)
+	if err != nil {
+		return nil, err
+	}
 	if body != nil {
 		req.Header.Add("Content-Type", "application/json")
 	}
 	req.SetBasicAuth(viper.GetString("username"), viper.GetString("bb_token"))
 
 	resp, err := client.Do(req)
-	cobra.CheckErr(err)
-
-	if resp.StatusCode != 201 && resp.StatusCode != 200 && resp.StatusCode != 204 {
-		errBody, err := io.ReadAll(resp.Body)
-		cobra.CheckErr(strings.Join([]string{fmt.Sprintf("%v", err), string(errBody)}, ","))
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	responseBody, err := io.ReadAll(resp.Body)
-	cobra.CheckErr(err)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 204 {
+		var errResponse ErrorResponse
+		if json.Unmarshal(responseBody, &errResponse) == nil && errResponse.Error.Message != "" {
+			return nil, fmt.Errorf("%s", errResponse.Error.Message)
+		}
+		return nil, fmt.Errorf("%s", string(responseBody))
+	}
+	return responseBody, nil
+}
 
-	return responseBody
+/* GET with a Range header that returns the status code and leaves handling it to the caller */
+func bbApiRangedRequest(endpoint string, dataRange string) ([]byte, int, error) {
+	client := &http.Client{}
+	url := fmt.Sprintf("%s/%s", viper.GetString("bb_api"), endpoint)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.SetBasicAuth(viper.GetString("username"), viper.GetString("bb_token"))
+	req.Header.Add("Range", fmt.Sprintf("bytes=%s", dataRange))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
 }
 
 func bbApiPost(endpoint string, body io.Reader) []byte {
@@ -129,24 +197,8 @@ func bbApiPut(endpoint string, body io.Reader) []byte {
 }
 
 func bbApiDelete(endpoint string) []byte {
-	client := &http.Client{}
-	url := fmt.Sprintf("%s/%s", viper.GetString("bb_api"), endpoint)
-	req, err := http.NewRequest("DELETE", url, nil)
+	body, err := bbApiRequest("DELETE", endpoint, nil)
 	cobra.CheckErr(err)
-	req.SetBasicAuth(viper.GetString("username"), viper.GetString("bb_token"))
-
-	resp, err := client.Do(req)
-	cobra.CheckErr(err)
-
-	if resp.StatusCode != 204 {
-		errBody, err := io.ReadAll(resp.Body)
-		cobra.CheckErr(err)
-		cobra.CheckErr(string(errBody))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	cobra.CheckErr(err)
-
 	return body
 }
 
@@ -163,60 +215,117 @@ func GetUser() User {
 	return user
 }
 
-func GetPrList(
-	repository string,
-	states []string,
-	author string,
-	search string,
-	source string,
-	destination string,
-	pages int,
-	status bool,
-	participants bool,
-) <-chan PullRequest {
+// this should be fields=* but it doesn't work
+const prParticipantsFields = "fields=next,values.id,values.title,values.description,values.state,values.comment_count,values.task_count,values.author,values.closed_by,values.close_source_branch,values.draft,values.destination,values.source,values.links,values.status,values.created_on,values.updated_on,values.participants"
+
+type PrListOptions struct {
+	States        []string
+	Author        string // nickname
+	Search        string
+	Source        string
+	Destination   string
+	Draft         *bool
+	Reviewer      string // uuid
+	Participant   string // uuid
+	ApprovedBy    string // uuid
+	NotApprovedBy string // uuid
+	UpdatedSince  time.Time
+	CreatedBefore time.Time
+	Query         string // raw query added to the generated one
+	Sort          string
+	Pages         int
+	Status        bool
+	Participants  bool
+}
+
+/* Quotes a string to be used in a query */
+func BBQLString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+/* Builds the query to filter pull requests from the given options */
+func (options PrListOptions) BBQL() string {
+	clauses := []string{}
+	if len(options.States) > 0 {
+		stateClauses := []string{}
+		for _, s := range options.States {
+			stateClauses = append(stateClauses, "state = "+BBQLString(strings.ToUpper(s)))
+		}
+		clauses = append(clauses, "("+strings.Join(stateClauses, " OR ")+")")
+	}
+	if options.Author != "" {
+		clauses = append(clauses, "author.nickname = "+BBQLString(options.Author))
+	}
+	if options.Search != "" {
+		clauses = append(clauses, "title ~ "+BBQLString(options.Search))
+	}
+	if options.Source != "" {
+		clauses = append(clauses, "source.branch.name = "+BBQLString(options.Source))
+	}
+	if options.Destination != "" {
+		clauses = append(clauses, "destination.branch.name = "+BBQLString(options.Destination))
+	}
+	if options.Draft != nil {
+		clauses = append(clauses, fmt.Sprintf("draft = %t", *options.Draft))
+	}
+	if options.Reviewer != "" {
+		clauses = append(clauses, "reviewers.uuid = "+BBQLString(options.Reviewer))
+	}
+	if options.Participant != "" {
+		clauses = append(clauses, "participants.user.uuid = "+BBQLString(options.Participant))
+	}
+	if options.ApprovedBy != "" {
+		// the approval itself is checked on the results since conditions on a list can match different participants
+		clauses = append(clauses, "participants.user.uuid = "+BBQLString(options.ApprovedBy))
+	}
+	if !options.UpdatedSince.IsZero() {
+		clauses = append(clauses, "updated_on >= "+options.UpdatedSince.UTC().Format(time.RFC3339))
+	}
+	if !options.CreatedBefore.IsZero() {
+		clauses = append(clauses, "created_on < "+options.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	if options.Query != "" {
+		clauses = append(clauses, "("+options.Query+")")
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+/* Returns true if the pull request passes the filters that can't be expressed in a query */
+func (options PrListOptions) matches(pr PullRequest) bool {
+	for _, participant := range pr.Participants {
+		if options.NotApprovedBy != "" && participant.User.UUID == options.NotApprovedBy && participant.Approved {
+			return false
+		}
+		if options.ApprovedBy != "" && participant.User.UUID == options.ApprovedBy && participant.Approved {
+			return true
+		}
+	}
+	return options.ApprovedBy == ""
+}
+
+func GetPrList(repository string, options PrListOptions) <-chan PullRequest {
 	channel := make(chan PullRequest)
 	go func() {
 		defer close(channel)
 
-		stateQuery := ""
-		if len(states) > 0 {
-			stateQuery = "("
-			for i, s := range states {
-				if i == 0 {
-					stateQuery += fmt.Sprintf("state = \"%s\"", s)
-				} else {
-					stateQuery += fmt.Sprintf(" OR state = \"%s\"", s)
-				}
-			}
-			stateQuery += ")"
+		fieldsExpansion := ""
+		if options.Participants || options.ApprovedBy != "" || options.NotApprovedBy != "" {
+			fieldsExpansion = "&" + prParticipantsFields
 		}
-		authorQuery := ""
-		if author != "" {
-			authorQuery = fmt.Sprintf(" AND author.nickname = \"%s\"", author)
+		sort := options.Sort
+		if sort == "" {
+			sort = "-id"
 		}
-		searchQuery := ""
-		if search != "" {
-			searchQuery = fmt.Sprintf(" AND title ~ \"%s\"", search)
-		}
-		sourceQuery := ""
-		if source != "" {
-			sourceQuery = fmt.Sprintf(" AND source.branch.name = \"%s\"", source)
-		}
-		destinationQuery := ""
-		if destination != "" {
-			destinationQuery = fmt.Sprintf(" AND destination.branch.name = \"%s\"", destination)
-		}
-		participantsExpansion := ""
-		if participants {
-			// this should be fields=* but it doesn't work
-			participantsExpansion = "&fields=values.id,values.title,values.description,values.state,values.comment_count,values.task_count,values.author,values.closed_by,values.close_source_branch,values.destination,values.source,values.links,values.status,values.created_on,values.updated_on,values.participants"
+		pages := options.Pages
+		if pages == 0 {
+			pages = 1
 		}
 
 		var prevResponse BBPaginatedResponse[PullRequest]
 		for i := 0; i < pages; i++ {
 			var response []byte
 			if i == 0 {
-				response = bbApiGet(fmt.Sprintf("repositories/%s/pullrequests?sort=-id%s&q=%s", repository, participantsExpansion, url.QueryEscape(stateQuery+authorQuery+searchQuery+sourceQuery+destinationQuery)))
+				response = bbApiGet(fmt.Sprintf("repositories/%s/pullrequests?pagelen=50&sort=%s%s&q=%s", repository, url.QueryEscape(sort), fieldsExpansion, url.QueryEscape(options.BBQL())))
 			} else {
 				newUrl := strings.Replace(prevResponse.Next, viper.GetString("bb_api")+"/", "", 1)
 				if newUrl == "" {
@@ -224,12 +333,16 @@ func GetPrList(
 				}
 				response = bbApiGet(newUrl)
 			}
+			prevResponse = BBPaginatedResponse[PullRequest]{}
 			err := json.Unmarshal(response, &prevResponse)
 			cobra.CheckErr(err)
 
 			// yield the value on the channel
 			for _, pr := range prevResponse.Values {
-				if status {
+				if !options.matches(pr) {
+					continue
+				}
+				if options.Status {
 					status := <-GetPrStatuses(repository, pr.ID)
 					if status != nil && len(status) > 0 {
 						// TODO FIX instead of getting the first one get the latest one
@@ -243,6 +356,44 @@ func GetPrList(
 	return channel
 }
 
+/* Returns the open pull requests of a repository matching a query, including participant data */
+func GetPrListByQuery(repository string, query string) <-chan []PullRequest {
+	channel := make(chan []PullRequest)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[PullRequest](fmt.Sprintf("repositories/%s/pullrequests?pagelen=50&%s&q=%s", repository, prParticipantsFields, url.QueryEscape(query)))
+	}()
+	return channel
+}
+
+/* Returns the pull requests authored by a user across a workspace, including participant data. Pages set to 0 retrieves all of them */
+func GetWorkspacePrsByUser(workspace string, userUuid string, options PrListOptions) <-chan []PullRequest {
+	channel := make(chan []PullRequest)
+	go func() {
+		defer close(channel)
+		options.Author = "" // already selected by the endpoint
+		sort := options.Sort
+		if sort == "" {
+			sort = "-updated_on"
+		}
+		prs := []PullRequest{}
+		endpoint := fmt.Sprintf("workspaces/%s/pullrequests/%s?pagelen=50&sort=%s&%s&q=%s", workspace, url.PathEscape(userUuid), url.QueryEscape(sort), prParticipantsFields, url.QueryEscape(options.BBQL()))
+		for page := 0; endpoint != "" && (options.Pages == 0 || page < options.Pages); page++ {
+			var paginatedResponse BBPaginatedResponse[PullRequest]
+			err := json.Unmarshal(bbApiGet(endpoint), &paginatedResponse)
+			cobra.CheckErr(err)
+			for _, pr := range paginatedResponse.Values {
+				if options.matches(pr) {
+					prs = append(prs, pr)
+				}
+			}
+			endpoint = strings.Replace(paginatedResponse.Next, viper.GetString("bb_api")+"/", "", 1)
+		}
+		channel <- prs
+	}()
+	return channel
+}
+
 func GetPr(repository string, id int) <-chan PullRequest {
 	channel := make(chan PullRequest)
 	go func() {
@@ -269,15 +420,142 @@ func GetPrStatuses(repository string, id int) <-chan []CommitStatus {
 	return channel
 }
 
+func GetPrCommits(repository string, id int) <-chan []Commit {
+	channel := make(chan []Commit)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[Commit](fmt.Sprintf("repositories/%s/pullrequests/%d/commits?pagelen=100", repository, id))
+	}()
+	return channel
+}
+
+func GetPrActivity(repository string, id int) <-chan []PrActivity {
+	channel := make(chan []PrActivity)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[PrActivity](fmt.Sprintf("repositories/%s/pullrequests/%d/activity?pagelen=50", repository, id))
+	}()
+	return channel
+}
+
+/* Lists commits reachable from include but not from exclude */
+func GetCommitsBetween(repository string, include string, exclude string) <-chan []Commit {
+	channel := make(chan []Commit)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[Commit](fmt.Sprintf("repositories/%s/commits?include=%s&exclude=%s&pagelen=100", repository, url.QueryEscape(include), url.QueryEscape(exclude)))
+	}()
+	return channel
+}
+
 func GetPrComments(repository string, id int) <-chan []PrComment {
 	channel := make(chan []PrComment)
 	go func() {
 		defer close(channel)
-		var paginatedResponse BBPaginatedResponse[PrComment]
-		response := bbApiGet(fmt.Sprintf("repositories/%s/pullrequests/%d/comments", repository, id))
-		err := json.Unmarshal(response, &paginatedResponse)
+		channel <- bbApiGetAllPages[PrComment](fmt.Sprintf("repositories/%s/pullrequests/%d/comments?pagelen=100", repository, id))
+	}()
+	return channel
+}
+
+func GetPrComment(repository string, id int, commentId int) <-chan PrComment {
+	channel := make(chan PrComment)
+	go func() {
+		defer close(channel)
+		var comment PrComment
+		response := bbApiGet(fmt.Sprintf("repositories/%s/pullrequests/%d/comments/%d", repository, id, commentId))
+		err := json.Unmarshal(response, &comment)
 		cobra.CheckErr(err)
-		channel <- paginatedResponse.Values
+		channel <- comment
+	}()
+	return channel
+}
+
+func PostPrComment(repository string, id int, data CreateCommentBody) PrComment {
+	content, err := json.Marshal(data)
+	cobra.CheckErr(err)
+	response := bbApiPost(fmt.Sprintf("repositories/%s/pullrequests/%d/comments", repository, id), bytes.NewReader(content))
+
+	var comment PrComment
+	err = json.Unmarshal(response, &comment)
+	cobra.CheckErr(err)
+	return comment
+}
+
+func UpdatePrComment(repository string, id int, commentId int, raw string) PrComment {
+	data := CreateCommentBody{}
+	data.Content.Raw = raw
+	content, err := json.Marshal(data)
+	cobra.CheckErr(err)
+	response := bbApiPut(fmt.Sprintf("repositories/%s/pullrequests/%d/comments/%d", repository, id, commentId), bytes.NewReader(content))
+
+	var comment PrComment
+	err = json.Unmarshal(response, &comment)
+	cobra.CheckErr(err)
+	return comment
+}
+
+func DeletePrComment(repository string, id int, commentId int) {
+	bbApiDelete(fmt.Sprintf("repositories/%s/pullrequests/%d/comments/%d", repository, id, commentId))
+}
+
+func GetPrTasks(repository string, id int) <-chan []PrTask {
+	channel := make(chan []PrTask)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[PrTask](fmt.Sprintf("repositories/%s/pullrequests/%d/tasks?pagelen=100", repository, id))
+	}()
+	return channel
+}
+
+func PostPrTask(repository string, id int, data CreateTaskBody) PrTask {
+	content, err := json.Marshal(data)
+	cobra.CheckErr(err)
+	response := bbApiPost(fmt.Sprintf("repositories/%s/pullrequests/%d/tasks", repository, id), bytes.NewReader(content))
+
+	var task PrTask
+	err = json.Unmarshal(response, &task)
+	cobra.CheckErr(err)
+	return task
+}
+
+func UpdatePrTask(repository string, id int, taskId int, data CreateTaskBody) PrTask {
+	content, err := json.Marshal(data)
+	cobra.CheckErr(err)
+	response := bbApiPut(fmt.Sprintf("repositories/%s/pullrequests/%d/tasks/%d", repository, id, taskId), bytes.NewReader(content))
+
+	var task PrTask
+	err = json.Unmarshal(response, &task)
+	cobra.CheckErr(err)
+	return task
+}
+
+func DeletePrTask(repository string, id int, taskId int) {
+	bbApiDelete(fmt.Sprintf("repositories/%s/pullrequests/%d/tasks/%d", repository, id, taskId))
+}
+
+func GetPrDiff(repository string, id int) <-chan string {
+	channel := make(chan string)
+	go func() {
+		defer close(channel)
+		channel <- string(bbApiGet(fmt.Sprintf("repositories/%s/pullrequests/%d/diff", repository, id)))
+	}()
+	return channel
+}
+
+func GetPrDiffStat(repository string, id int) <-chan []DiffStat {
+	channel := make(chan []DiffStat)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[DiffStat](fmt.Sprintf("repositories/%s/pullrequests/%d/diffstat?pagelen=500", repository, id))
+	}()
+	return channel
+}
+
+func GetRepositoryList(workspace string) <-chan []Repository {
+	channel := make(chan []Repository)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[Repository](fmt.Sprintf("repositories/%s?pagelen=100&fields=next,values.full_name,values.name,values.slug,values.updated_on", workspace))
 	}()
 	return channel
 }
@@ -286,27 +564,63 @@ func GetReviewers(repository string) <-chan []User {
 	channel := make(chan []User)
 	go func() {
 		defer close(channel)
-		var paginatedResponse BBPaginatedResponse[User]
-		response := bbApiGet(fmt.Sprintf("repositories/%s/effective-default-reviewers", repository))
-		err := json.Unmarshal(response, &paginatedResponse)
-		cobra.CheckErr(err)
-		channel <- paginatedResponse.Values
+		var users []User
+		for _, reviewer := range <-GetEffectiveDefaultReviewers(repository) {
+			users = append(users, reviewer.User)
+		}
+		channel <- users
 	}()
 	return channel
 }
 
+/* Default reviewers of the repository including the ones inherited from its project */
+func GetEffectiveDefaultReviewers(repository string) <-chan []DefaultReviewer {
+	channel := make(chan []DefaultReviewer)
+	go func() {
+		defer close(channel)
+		channel <- bbApiGetAllPages[DefaultReviewer](fmt.Sprintf("repositories/%s/effective-default-reviewers?pagelen=100", repository))
+	}()
+	return channel
+}
+
+func AddDefaultReviewer(repository string, user User) error {
+	_, err := bbApiRequest("PUT", fmt.Sprintf("repositories/%s/default-reviewers/%s", repository, url.PathEscape(user.UUID)), nil)
+	return err
+}
+
+func RemoveDefaultReviewer(repository string, user User) error {
+	_, err := bbApiRequest("DELETE", fmt.Sprintf("repositories/%s/default-reviewers/%s", repository, url.PathEscape(user.UUID)), nil)
+	return err
+}
+
 func GetWorkspaceMembers(workspace string) <-chan []User {
 	channel := make(chan []User)
 	go func() {
 		defer close(channel)
-		var paginatedResponse BBPaginatedResponse[struct {
+		var users []User
+		for _, r := range bbApiGetAllPages[struct {
 			User User `json:"user"`
-		}]
-		response := bbApiGet(fmt.Sprintf("workspaces/%s/members", workspace))
-		err := json.Unmarshal(response, &paginatedResponse)
-		cobra.CheckErr(err)
+		}](fmt.Sprintf("workspaces/%s/members?pagelen=100", workspace)) {
+			users = append(users, r.User)
+		}
+		channel <- users
+	}()
+	return channel
+}
+
+func GetWorkspaceMembersByEmail(workspace string, emails []string) <-chan []User {
+	channel := make(chan []User)
+	go func() {
+		defer close(channel)
+		quoted := []string{}
+		for _, email := range emails {
+			quoted = append(quoted, BBQLString(email))
+		}
+		query := fmt.Sprintf("user.email IN (%s)", strings.Join(quoted, ","))
 		var users []User
-		for _, r := range paginatedResponse.Values {
+		for _, r := range bbApiGetAllPages[struct {
+			User User `json:"user"`
+		}](fmt.Sprintf("workspaces/%s/members?q=%s", workspace, url.QueryEscape(query))) {
 			users = append(users, r.User)
 		}
 		channel <- users
@@ -338,34 +652,70 @@ func UpdatePr(repository string, id int, data CreatePullRequestBody) PullRequest
 	return pr
 }
 
-func ApprovePr(repository string, id int) {
-	bbApiPost(fmt.Sprintf("repositories/%s/pullrequests/%d/approve", repository, id), nil)
+/* Returns an update body that keeps every field of pr as it is */
+func PrUpdateBody(pr PullRequest) CreatePullRequestBody {
+	body := CreatePullRequestBody{
+		Title:       pr.Title,
+		Description: pr.Description,
+		CloseSource: pr.CloseSource,
+		Reviewers:   []ReviewerBody{},
+	}
+	for _, reviewer := range pr.Reviewers {
+		body.Reviewers = append(body.Reviewers, ReviewerBody{AccountId: reviewer.AccountId})
+	}
+	return body
+}
+
+func ApprovePr(repository string, id int) error {
+	_, err := bbApiRequest("POST", fmt.Sprintf("repositories/%s/pullrequests/%d/approve", repository, id), nil)
+	return err
 }
 
-func MergePr(repository string, id int, message string) {
-	content, err := json.Marshal(struct {
-		Message string `json:"message"`
-	}{
-		Message: message,
-	})
+func MergePr(repository string, id int, data MergePullRequestBody) error {
+	content, err := json.Marshal(data)
 	cobra.CheckErr(err)
-	payload := bytes.NewReader(content)
-	if message == "" {
-		payload = nil
-	}
-	bbApiPost(fmt.Sprintf("repositories/%s/pullrequests/%d/merge", repository, id), payload)
+	_, err = bbApiRequest("POST", fmt.Sprintf("repositories/%s/pullrequests/%d/merge", repository, id), bytes.NewReader(content))
+	return err
 }
 
-func UnnaprovePr(repository string, id int) {
-	bbApiDelete(fmt.Sprintf("repositories/%s/pullrequests/%d/approve", repository, id))
+func UnnaprovePr(repository string, id int) error {
+	_, err := bbApiRequest("DELETE", fmt.Sprintf("repositories/%s/pullrequests/%d/approve", repository, id), nil)
+	return err
 }
 
-func DeclinePr(repository string, id int) {
-	bbApiPost(fmt.Sprintf("repositories/%s/pullrequests/%d/decline", repository, id), nil)
+func DeclinePr(repository string, id int) error {
+	_, err := bbApiRequest("POST", fmt.Sprintf("repositories/%s/pullrequests/%d/decline", repository, id), nil)
+	return err
 }
 
-func RequestChangesPr(repository string, id int) {
-	bbApiPost(fmt.Sprintf("repositories/%s/pullrequests/%d/request-changes", repository, id), nil)
+func RequestChangesPr(repository string, id int) error {
+	_, err := bbApiRequest("POST", fmt.Sprintf("repositories/%s/pullrequests/%d/request-changes", repository, id), nil)
+	return err
+}
+
+func UnrequestChangesPr(repository string, id int) error {
+	_, err := bbApiRequest("DELETE", fmt.Sprintf("repositories/%s/pullrequests/%d/request-changes", repository, id), nil)
+	return err
+}
+
+/* Returns the branch restrictions of kind, the error is returned since reading them requires admin access */
+func GetBranchRestrictions(repository string, kind string) ([]BranchRestriction, error) {
+	endpoint := fmt.Sprintf("repositories/%s/branch-restrictions?pagelen=100", repository)
+	if kind != "" {
+		endpoint += "&kind=" + url.QueryEscape(kind)
+	}
+	return bbApiRequestAllPages[BranchRestriction](endpoint)
+}
+
+/* Returns the content of a file of the repository at ref, which can be a branch, tag or commit */
+func GetFileContent(repository string, ref string, path string) ([]byte, error) {
+	return bbApiRequest("GET", fmt.Sprintf("repositories/%s/src/%s/%s", repository, url.PathEscape(ref), path), nil)
+}
+
+/* Returns if branch exists on the remote repository */
+func BranchExists(repository string, branch string) bool {
+	_, err := bbApiRequest("GET", fmt.Sprintf("repositories/%s/refs/branches/%s", repository, url.PathEscape(branch)), nil)
+	return err == nil
 }
 
 func GetPipelineList(repository string, nResults int, targetBranch string) <-chan Pipeline {
@@ -406,15 +756,27 @@ func GetPipelineSteps(repository string, id string) <-chan []PipelineStep {
 	channel := make(chan []PipelineStep)
 	go func() {
 		defer close(channel)
-		var steps BBPaginatedResponse[PipelineStep]
-		response := bbApiGet(fmt.Sprintf("repositories/%s/pipelines/%s/steps", repository, id))
-		err := json.Unmarshal(response, &steps)
-		cobra.CheckErr(err)
-		channel <- steps.Values
+		channel <- bbApiGetAllPages[PipelineStep](fmt.Sprintf("repositories/%s/pipelines/%s/steps?pagelen=100", repository, id))
 	}()
 	return channel
 }
 
+/* Same as GetPipeline but returns the error instead of exiting, for commands that poll */
+func ReadPipeline(repository string, id string) (Pipeline, error) {
+	var pipeline Pipeline
+	response, err := bbApiRequest("GET", fmt.Sprintf("repositories/%s/pipelines/%s", repository, id), nil)
+	if err != nil {
+		return pipeline, err
+	}
+	err = json.Unmarshal(response, &pipeline)
+	return pipeline, err
+}
+
+/* Same as GetPipelineSteps but returns the error instead of exiting, for commands that poll */
+func ReadPipelineSteps(repository string, id string) ([]PipelineStep, error) {
+	return bbApiRequestAllPages[PipelineStep](fmt.Sprintf("repositories/%s/pipelines/%s/steps?pagelen=100", repository, id))
+}
+
 func GetPipelineStep(repository string, id string, stepId string) <-chan PipelineStep {
 	channel := make(chan PipelineStep)
 	go func() {
@@ -442,6 +804,20 @@ func GetPipelineStepLogs(repository string, id string, stepId string, offset int
 	return channel
 }
 
+/* Reads the log of a step from offset. Steps that just started have no log yet, which is read as empty */
+func ReadPipelineStepLog(repository string, id string, stepId string, offset int) (string, error) {
+	response, status, err := bbApiRangedRequest(fmt.Sprintf("repositories/%s/pipelines/%s/steps/%s/log", repository, id, stepId), fmt.Sprintf("%d-", offset))
+	switch {
+	case err != nil:
+		return "", err
+	case status == http.StatusNotFound || status == http.StatusRequestedRangeNotSatisfiable:
+		return "", nil
+	case status != http.StatusOK && status != http.StatusPartialContent:
+		return "", fmt.Errorf("%s", string(response))
+	}
+	return string(response), nil
+}
+
 func GetPipelineReport(repository string, id string, stepId string) <-chan PipelineReport {
 	channel := make(chan PipelineReport)
 	go func() {
@@ -562,6 +938,11 @@ func GetEnvironmentList(repository string, status bool) <-chan Environment {
 	return channel
 }
 
+/* Returns every environment of the repository, the error is returned instead of exiting */
+func GetAllEnvironments(repository string) ([]Environment, error) {
+	return bbApiRequestAllPages[Environment](fmt.Sprintf("repositories/%s/environments?pagelen=100", repository))
+}
+
 func GetEnvironmentVariables(repository string, envName string) <-chan EnvironmentVariable {
 	channel := make(chan EnvironmentVariable)
 	go func() {
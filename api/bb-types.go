@@ -24,6 +24,13 @@ type User struct {
 	Links       struct{ Html struct{ Href string } }
 }
 
+type Repository struct {
+	FullName  string    `json:"full_name"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
 type PrState string
 
 const (
@@ -33,12 +40,30 @@ const (
 	SUPERSEDED PrState = "superseded"
 )
 
+type DefaultReviewer struct {
+	ReviewerType string `json:"reviewer_type"` // "repository" or "project"
+	User         User   `json:"user"`
+}
+
 type Branch struct {
 	Branch struct {
 		Name string `json:"name"`
 	} `json:"branch"`
 }
 
+type BranchRef struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		Links    struct{ Html struct{ Href string } }
+	} `json:"repository"`
+}
+
 type PullRequest struct {
 	ID           int     `json:"id"`
 	Title        string  `json:"title"`
@@ -49,12 +74,14 @@ type PullRequest struct {
 	Author       User    `json:"author"`
 	ClosedBy     User    `json:"closed_by"`
 	CloseSource  bool    `json:"close_source_branch"`
-	Destination  Branch
-	Source       Branch
+	Draft        bool    `json:"draft"`
+	Destination  BranchRef
+	Source       BranchRef
 	Links        struct{ Html struct{ Href string } }
 	Status       CommitStatus
 	CreatedOn    time.Time `json:"created_on"`
 	UpdatedOn    time.Time `json:"updated_on"`
+	Reviewers    []User    `json:"reviewers"`
 	Participants []struct {
 		User           User `json:"user"`
 		Role           string
@@ -68,11 +95,38 @@ type CreatePullRequestBody struct {
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
 	CloseSource bool    `json:"close_source_branch"`
+	Draft       *bool   `json:"draft,omitempty"`
 	Destination *Branch `json:"destination,omitempty"`
 	Source      *Branch `json:"source,omitempty"`
-	Reviewers   []struct {
-		AccountId string `json:"account_id"`
-	} `json:"reviewers,omitempty"`
+	// always sent since bitbucket drops reviewers missing from an update
+	Reviewers []ReviewerBody `json:"reviewers"`
+}
+
+type ReviewerBody struct {
+	AccountId string `json:"account_id"`
+}
+
+type MergeStrategy string
+
+const (
+	MERGE_COMMIT MergeStrategy = "merge_commit"
+	SQUASH       MergeStrategy = "squash"
+	FAST_FORWARD MergeStrategy = "fast_forward"
+)
+
+type MergePullRequestBody struct {
+	Message     string        `json:"message,omitempty"`
+	CloseSource *bool         `json:"close_source_branch,omitempty"`
+	Strategy    MergeStrategy `json:"merge_strategy,omitempty"`
+}
+
+type BranchRestriction struct {
+	Id              int    `json:"id"`
+	Kind            string `json:"kind"`
+	BranchMatchKind string `json:"branch_match_kind"`
+	BranchType      string `json:"branch_type"`
+	Pattern         string `json:"pattern"`
+	Value           int    `json:"value"`
 }
 
 type RunPipelineRequestBody struct {
@@ -84,6 +138,13 @@ type RunPipelineRequestBody struct {
 		PullRequest *PipelinePullRequestBody `json:"pullrequest"`
 		Selector    *PipelineSelectorBody    `json:"selector"`
 	} `json:"target"`
+	Variables []PipelineVariableBody `json:"variables,omitempty"`
+}
+
+type PipelineVariableBody struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Secured bool   `json:"secured,omitempty"`
 }
 
 type PipelineSelectorBody struct {
@@ -109,6 +170,19 @@ type CommitStatus struct {
 	UpdatedOn time.Time `json:"updated_on"`
 }
 
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Author  struct {
+		Raw  string `json:"raw"`
+		User User   `json:"user"`
+	} `json:"author"`
+	Parents []struct {
+		Hash string `json:"hash"`
+	} `json:"parents"`
+}
+
 type Pipeline struct {
 	UUID        string
 	BuildNumber int `json:"build_number"`
@@ -117,6 +191,9 @@ type Pipeline struct {
 		Result struct {
 			Name string
 		}
+		Stage struct {
+			Name string
+		}
 	} `json:"state"`
 	Target struct {
 		Source      string
@@ -140,7 +217,8 @@ type Pipeline struct {
 type PipelineStep struct {
 	UUID              string
 	Name              string
-	DurationInSeconds int `json:"duration_in_seconds"`
+	DurationInSeconds int       `json:"duration_in_seconds"`
+	StartedOn         time.Time `json:"started_on"`
 	State             struct {
 		Name   string
 		Result struct {
@@ -190,14 +268,99 @@ type PrComment struct {
 		Raw  string
 		Html string
 	}
-	User      User
-	Deleted   bool
-	Type      string
+	User       User
+	Deleted    bool
+	Type       string
+	Inline     *CommentInline `json:"inline"`
+	Parent     *CommentParent `json:"parent"`
+	Resolution *struct {
+		User      User      `json:"user"`
+		CreatedOn time.Time `json:"created_on"`
+	} `json:"resolution"`
 	Links     struct{ Html struct{ Href string } }
 	CreatedOn time.Time `json:"created_on"`
 	UpdatedOn time.Time `json:"updated_on"`
 }
 
+type PrActivityUpdate struct {
+	State       string    `json:"state"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Date        time.Time `json:"date"`
+	Author      User      `json:"author"`
+	Source      BranchRef `json:"source"`
+	Destination BranchRef `json:"destination"`
+	Reviewers   []User    `json:"reviewers"`
+	Draft       bool      `json:"draft"`
+}
+
+type PrActivityReview struct {
+	Date time.Time `json:"date"`
+	User User      `json:"user"`
+}
+
+/* An entry of the activity log of a pull request, only one of the fields is set */
+type PrActivity struct {
+	Update           *PrActivityUpdate `json:"update"`
+	Approval         *PrActivityReview `json:"approval"`
+	ChangesRequested *PrActivityReview `json:"changes_requested"`
+	Comment          *PrComment        `json:"comment"`
+}
+
+type CommentInline struct {
+	Path     string `json:"path"`
+	From     *int   `json:"from,omitempty"`     // line on the old version of the file
+	To       *int   `json:"to,omitempty"`       // line on the new version of the file
+	Outdated bool   `json:"outdated,omitempty"` // the line changed since the comment was made
+}
+
+type CommentParent struct {
+	Id int `json:"id"`
+}
+
+type CreateCommentBody struct {
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Inline *CommentInline `json:"inline,omitempty"`
+	Parent *CommentParent `json:"parent,omitempty"`
+}
+
+type PrTask struct {
+	Id      int    `json:"id"`
+	State   string `json:"state"` // RESOLVED or UNRESOLVED
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Creator    User  `json:"creator"`
+	ResolvedBy *User `json:"resolved_by"`
+	Comment    *struct {
+		Id int `json:"id"`
+	} `json:"comment"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+type CreateTaskBody struct {
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Comment *CommentParent `json:"comment,omitempty"`
+	State   string         `json:"state,omitempty"`
+}
+
+type DiffStat struct {
+	Status       string        `json:"status"`
+	LinesAdded   int           `json:"lines_added"`
+	LinesRemoved int           `json:"lines_removed"`
+	Old          *DiffStatFile `json:"old"`
+	New          *DiffStatFile `json:"new"`
+}
+
+type DiffStatFile struct {
+	Path string `json:"path"`
+}
+
 type Environment struct {
 	UUID     string `json:"uuid"`
 	Name     string
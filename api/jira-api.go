@@ -37,21 +37,33 @@ func JiraBrowse(domain string, key string) string {
 // REST
 
 func jiraApiGet(endpoint string) []byte {
+	body, err := jiraApiRequest(endpoint)
+	cobra.CheckErr(err)
+	return body
+}
+
+/* Same as jiraApiGet but returns errors instead of exiting */
+func jiraApiRequest(endpoint string) ([]byte, error) {
 	client := &http.Client{}
 	url := fmt.Sprintf("%s/%s", JiraEndpoint(viper.GetString("jira_domain")), endpoint)
 	req, err := http.NewRequest("GET", url, nil)
-	cobra.CheckErr(err)
+	if err != nil {
+		return nil, err
+	}
 	req.SetBasicAuth(viper.GetString("email"), viper.GetString("jira_token"))
 	resp, err := client.Do(req)
-	cobra.CheckErr(err)
-	if resp.StatusCode != 200 {
-		errBody, err := io.ReadAll(resp.Body)
-		cobra.CheckErr(err)
-		cobra.CheckErr(string(errBody))
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	cobra.CheckErr(err)
-	return body
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s", string(body))
+	}
+	return body, nil
 }
 
 func _jiraApiPostPut(method string, endpoint string, body io.Reader) []byte {
@@ -106,6 +118,20 @@ func GetIssue(key string) <-chan JiraIssue {
 	return channel
 }
 
+/* Like GetIssue but yields an issue with an empty key when it can't be retrieved, such as a key that only looks like one */
+func FindIssue(key string) <-chan JiraIssue {
+	channel := make(chan JiraIssue)
+	go func() {
+		defer close(channel)
+		var issue JiraIssue
+		if response, err := jiraApiRequest(fmt.Sprintf("/issue/%s", key)); err == nil {
+			json.Unmarshal(response, &issue)
+		}
+		channel <- issue
+	}()
+	return channel
+}
+
 func GetIssueList(nResults int, all bool, reporter bool, project string, statuses []string, types []string, searchTerm string, prioritySort bool, lastWorked bool) <-chan JiraIssue {
 	channel := make(chan JiraIssue)
 	go func() {
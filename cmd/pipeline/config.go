@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"bb/api"
+	"bb/util"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const pipelinesConfigFile = "bitbucket-pipelines.yml"
+
+type pipelineVariable struct {
+	Name          string   `yaml:"name"`
+	Default       string   `yaml:"default"`
+	Description   string   `yaml:"description"`
+	AllowedValues []string `yaml:"allowed-values"`
+}
+
+type customPipeline struct {
+	Name      string
+	Variables []pipelineVariable
+}
+
+/* Reads bitbucket-pipelines.yml at revision locally or from bitbucket, falling back to the working tree. Returns false when the file is not the one at revision */
+func readPipelinesConfig(repo string, revision string) ([]byte, bool, error) {
+	if revision != "" {
+		if content, err := util.ShowFile(util.RemoteOrLocalRef(revision), pipelinesConfigFile); err == nil {
+			return []byte(content), true, nil
+		}
+		if repo != "" {
+			if content, err := api.GetFileContent(repo, revision, pipelinesConfigFile); err == nil {
+				return content, true, nil
+			}
+		}
+	}
+	root, err := util.GetRepoRoot()
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := os.ReadFile(filepath.Join(root, pipelinesConfigFile))
+	return content, false, err
+}
+
+/* Returns the custom pipelines of the configuration sorted by name, with the variables each one declares */
+func customPipelines(content []byte) ([]customPipeline, error) {
+	var config struct {
+		Pipelines struct {
+			Custom map[string][]struct {
+				Variables []pipelineVariable `yaml:"variables"`
+			} `yaml:"custom"`
+		} `yaml:"pipelines"`
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	pipelines := []customPipeline{}
+	for name, items := range config.Pipelines.Custom {
+		pipeline := customPipeline{Name: name}
+		for _, item := range items {
+			pipeline.Variables = append(pipeline.Variables, item.Variables...)
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	sort.Slice(pipelines, func(i, j int) bool { return pipelines[i].Name < pipelines[j].Name })
+	return pipelines, nil
+}
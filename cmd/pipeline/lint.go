@@ -0,0 +1,495 @@
+package pipeline
+
+import (
+	"bb/api"
+	"bb/util"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed bitbucket-pipelines.schema.json
+var pipelinesSchema []byte
+
+// caches and services bitbucket provides without a definition
+var builtinCaches = []string{"composer", "docker", "dotnetcore", "gradle", "ivy2", "maven", "node", "pip", "sbt"}
+var builtinServices = []string{"docker"}
+
+var LintCmd = &cobra.Command{
+	Use:   "lint [FILE]",
+	Short: "Validate a bitbucket-pipelines.yml file",
+	Long: `Validate the structure of a bitbucket-pipelines.yml file against a bundled schema, reporting problems with their line numbers.
+	Caches and services used by the steps must be defined and deployment environments are checked to exist in the repository, unless --offline is given.
+	If no FILE is given the bitbucket-pipelines.yml at the root of the repository is used`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		offline, _ := cmd.Flags().GetBool("offline")
+
+		file := pipelinesConfigFile
+		var content []byte
+		var err error
+		if len(args) > 0 {
+			file = args[0]
+			content, err = os.ReadFile(file)
+		} else {
+			content, _, err = readPipelinesConfig("", "")
+		}
+		cobra.CheckErr(err)
+
+		var document yaml.Node
+		cobra.CheckErr(yaml.Unmarshal(content, &document))
+		if len(document.Content) == 0 {
+			cobra.CheckErr(fmt.Sprintf("%s is empty", file))
+		}
+		root := document.Content[0]
+
+		var schema lintSchema
+		cobra.CheckErr(json.Unmarshal(pipelinesSchema, &schema))
+		linter := pipelinesLinter{schema: &schema}
+		problems := linter.validate(root, &schema, "")
+		problems = append(problems, lintReferences(root)...)
+		if !offline {
+			problems = append(problems, lintEnvironments(root, viper.GetString("repo"))...)
+		}
+
+		sort.SliceStable(problems, func(i, j int) bool {
+			if problems[i].Line != problems[j].Line {
+				return problems[i].Line < problems[j].Line
+			}
+			return problems[i].Column < problems[j].Column
+		})
+		for _, problem := range problems {
+			util.Printf("\033[1m%s:%d:%d:\033[m %s\n", file, problem.Line, problem.Column, problem.Message)
+		}
+		if len(problems) > 0 {
+			cobra.CheckErr(fmt.Sprintf("%d problems found in %s", len(problems), file))
+		}
+		util.Printf("\033[1;32m✓\033[m %s is valid\n", file)
+	},
+}
+
+func init() {
+	LintCmd.Flags().Bool("offline", false, "only validate against the bundled schema, without checking deployment environments on bitbucket")
+}
+
+type lintProblem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+/* Subset of JSON schema used by the bundled schema */
+type lintSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Enum                 []string               `json:"enum"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*lintSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *lintSchema            `json:"items"`
+	OneOf                []*lintSchema          `json:"oneOf"`
+	MinItems             int                    `json:"minItems"`
+	MaxItems             int                    `json:"maxItems"`
+	MinProperties        int                    `json:"minProperties"`
+	Definitions          map[string]*lintSchema `json:"definitions"`
+}
+
+/* Returns if properties not listed are allowed and the schema they must follow, if any */
+func (s *lintSchema) additional() (bool, *lintSchema) {
+	if len(s.AdditionalProperties) == 0 || string(s.AdditionalProperties) == "true" {
+		return true, nil
+	} else if string(s.AdditionalProperties) == "false" {
+		return false, nil
+	}
+	var schema lintSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &schema); err != nil {
+		return true, nil
+	}
+	return true, &schema
+}
+
+type pipelinesLinter struct {
+	schema *lintSchema // root schema, where references are resolved
+}
+
+func (l pipelinesLinter) resolve(schema *lintSchema) *lintSchema {
+	for schema.Ref != "" {
+		definition, ok := l.schema.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+		if !ok {
+			cobra.CheckErr(fmt.Sprintf("bad reference in pipelines schema: %s", schema.Ref))
+		}
+		schema = definition
+	}
+	return schema
+}
+
+func (l pipelinesLinter) validate(node *yaml.Node, schema *lintSchema, path string) []lintProblem {
+	node = resolveAlias(node)
+	schema = l.resolve(schema)
+	at := func(node *yaml.Node, format string, a ...any) []lintProblem {
+		message := fmt.Sprintf(format, a...)
+		if path != "" {
+			message = path + ": " + message
+		}
+		return []lintProblem{{node.Line, node.Column, message}}
+	}
+
+	if len(schema.OneOf) > 0 {
+		return l.validateOneOf(node, schema.OneOf, path, at)
+	}
+	if schema.Type != "" && !typeMatches(node, schema.Type) {
+		return at(node, "expected %s, got %s", schema.Type, nodeType(node))
+	}
+	if len(schema.Enum) > 0 {
+		for _, value := range schema.Enum {
+			if node.Kind == yaml.ScalarNode && node.Value == value {
+				return nil
+			}
+		}
+		return at(node, "%q is not one of %s", node.Value, strings.Join(schema.Enum, ", "))
+	}
+
+	problems := []lintProblem{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		explicit := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key := node.Content[i]; key.Tag != "!!merge" && explicit[key.Value] {
+				problems = append(problems, at(key, "duplicate property %q", key.Value)...)
+			} else {
+				explicit[key.Value] = true
+			}
+		}
+		pairs := mappingPairs(node)
+		seen := map[string]bool{}
+		for _, pair := range pairs {
+			seen[pair[0].Value] = true
+		}
+		for _, required := range schema.Required {
+			if !seen[required] {
+				problems = append(problems, at(node, "missing required property %q", required)...)
+			}
+		}
+		if len(pairs) < schema.MinProperties {
+			problems = append(problems, at(node, "must not be empty")...)
+		}
+		allowed, additional := schema.additional()
+		for _, pair := range pairs {
+			key, value := pair[0], pair[1]
+			if property, ok := schema.Properties[key.Value]; ok {
+				problems = append(problems, l.validate(value, property, joinPath(path, key.Value))...)
+			} else if !allowed {
+				problems = append(problems, at(key, "unknown property %q", key.Value)...)
+			} else if additional != nil {
+				problems = append(problems, l.validate(value, additional, joinPath(path, key.Value))...)
+			}
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) < schema.MinItems {
+			problems = append(problems, at(node, "must not be empty")...)
+		}
+		if schema.MaxItems > 0 && len(node.Content) > schema.MaxItems {
+			problems = append(problems, at(node, "must not have more than %d items", schema.MaxItems)...)
+		}
+		if schema.Items != nil {
+			for i, item := range node.Content {
+				problems = append(problems, l.validate(item, schema.Items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+/* Validates a node matching any of the alternatives. When none matches the problems of the closest one are reported */
+func (l pipelinesLinter) validateOneOf(node *yaml.Node, alternatives []*lintSchema, path string, at func(*yaml.Node, string, ...any) []lintProblem) []lintProblem {
+	candidates := []*lintSchema{}
+	expected := []string{}
+	for _, alternative := range alternatives {
+		alternative = l.resolve(alternative)
+		problems := l.validate(node, alternative, path)
+		if len(problems) == 0 {
+			return nil
+		}
+		if alternative.Type == "" && len(alternative.Enum) > 0 {
+			expected = append(expected, strings.Join(alternative.Enum, ", "))
+		} else {
+			expected = append(expected, alternative.Type)
+		}
+		if alternative.Type == "" || typeMatches(node, alternative.Type) {
+			candidates = append(candidates, alternative)
+		}
+	}
+	if len(candidates) == 0 {
+		return at(node, "expected %s, got %s", strings.Join(unique(expected), " or "), nodeType(node))
+	}
+	if len(candidates) == 1 || node.Kind != yaml.MappingNode {
+		return l.validate(node, candidates[0], path)
+	}
+
+	// alternative objects are told apart by their required properties
+	keys := []string{}
+	for _, candidate := range candidates {
+		if len(candidate.Required) == 0 {
+			continue
+		}
+		if mappingValue(node, candidate.Required[0]) != nil {
+			return l.validate(node, candidate, path)
+		}
+		keys = append(keys, candidate.Required[0])
+	}
+	return at(node, "expected one of the properties %s", strings.Join(keys, ", "))
+}
+
+/* Checks that the caches and services used by steps are defined, and that deployments are not repeated in a pipeline */
+func lintReferences(root *yaml.Node) []lintProblem {
+	problems := []lintProblem{}
+	definitions := mappingValue(root, "definitions")
+	caches := toSet(append(mappingKeys(mappingValue(definitions, "caches")), builtinCaches...))
+	services := toSet(append(mappingKeys(mappingValue(definitions, "services")), builtinServices...))
+
+	for _, pipeline := range pipelineItemLists(root) {
+		deployments := map[string]bool{}
+		for _, deployment := range pipelineDeployments(pipeline) {
+			if deployments[deployment.Value] {
+				problems = append(problems, lintProblem{deployment.Line, deployment.Column, fmt.Sprintf("deployment environment %q is used more than once in the pipeline", deployment.Value)})
+			}
+			deployments[deployment.Value] = true
+		}
+		for _, step := range pipelineSteps(pipeline) {
+			for _, cache := range sequenceValues(mappingValue(step, "caches")) {
+				if !caches[cache.Value] {
+					problems = append(problems, lintProblem{cache.Line, cache.Column, fmt.Sprintf("cache %q is not defined in definitions.caches", cache.Value)})
+				}
+			}
+			for _, service := range sequenceValues(mappingValue(step, "services")) {
+				if !services[service.Value] {
+					problems = append(problems, lintProblem{service.Line, service.Column, fmt.Sprintf("service %q is not defined in definitions.services", service.Value)})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+/* Checks that the deployment environments used exist in the repository */
+func lintEnvironments(root *yaml.Node, repo string) []lintProblem {
+	deployments := []*yaml.Node{}
+	for _, pipeline := range pipelineItemLists(root) {
+		deployments = append(deployments, pipelineDeployments(pipeline)...)
+	}
+	if len(deployments) == 0 {
+		return nil
+	}
+	list, err := api.GetAllEnvironments(repo)
+	if err != nil {
+		util.Printf("\033[1;33mWarning:\033[m could not check the deployment environments: %s\n", err)
+		return nil
+	}
+	environments := map[string]bool{}
+	for _, env := range list {
+		environments[strings.ToLower(env.Name)] = true
+	}
+	problems := []lintProblem{}
+	for _, deployment := range deployments {
+		if !environments[strings.ToLower(deployment.Value)] {
+			problems = append(problems, lintProblem{deployment.Line, deployment.Column, fmt.Sprintf("deployment environment %q does not exist in %s", deployment.Value, repo)})
+		}
+	}
+	return problems
+}
+
+/* Returns the list of items of every pipeline */
+func pipelineItemLists(root *yaml.Node) []*yaml.Node {
+	pipelines := mappingValue(root, "pipelines")
+	lists := []*yaml.Node{}
+	for _, pair := range mappingPairs(pipelines) {
+		if pair[0].Value == "default" {
+			lists = append(lists, resolveAlias(pair[1]))
+			continue
+		}
+		for _, group := range mappingPairs(resolveAlias(pair[1])) {
+			lists = append(lists, resolveAlias(group[1]))
+		}
+	}
+	return lists
+}
+
+/* Returns the steps of a pipeline, including the ones inside parallel and stage items */
+func pipelineSteps(pipeline *yaml.Node) []*yaml.Node {
+	steps := []*yaml.Node{}
+	for _, item := range sequenceValues(pipeline) {
+		steps = append(steps, itemSteps(item)...)
+	}
+	return steps
+}
+
+func itemSteps(item *yaml.Node) []*yaml.Node {
+	items := []*yaml.Node{item}
+	if parallel := mappingValue(item, "parallel"); parallel != nil {
+		if parallel.Kind == yaml.MappingNode {
+			parallel = mappingValue(parallel, "steps")
+		}
+		items = sequenceValues(parallel)
+	} else if stage := mappingValue(item, "stage"); stage != nil {
+		items = sequenceValues(mappingValue(stage, "steps"))
+	}
+	steps := []*yaml.Node{}
+	for _, item := range items {
+		if step := mappingValue(item, "step"); step != nil && step.Kind == yaml.MappingNode {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+/* Returns the deployment values of the steps and stages of a pipeline, in the order they run */
+func pipelineDeployments(pipeline *yaml.Node) []*yaml.Node {
+	deployments := []*yaml.Node{}
+	add := func(node *yaml.Node) {
+		if deployment := mappingValue(node, "deployment"); deployment != nil && deployment.Kind == yaml.ScalarNode && deployment.Value != "" {
+			deployments = append(deployments, deployment)
+		}
+	}
+	for _, item := range sequenceValues(pipeline) {
+		add(mappingValue(item, "stage"))
+		for _, step := range itemSteps(item) {
+			add(step)
+		}
+	}
+	return deployments
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+/* Returns the key and value pairs of a mapping, expanding merge keys "<<" */
+func mappingPairs(node *yaml.Node) [][2]*yaml.Node {
+	node = resolveAlias(node)
+	pairs := [][2]*yaml.Node{}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return pairs
+	}
+	defined := map[string]bool{}
+	merges := []*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag == "!!merge" {
+			merges = append(merges, value)
+			continue
+		}
+		pairs = append(pairs, [2]*yaml.Node{key, value})
+		defined[key.Value] = true
+	}
+	// keys set explicitly override the merged ones, and earlier merged mappings override later ones
+	for _, value := range merges {
+		merged := []*yaml.Node{value}
+		if value = resolveAlias(value); value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		for _, mapping := range merged {
+			for _, pair := range mappingPairs(mapping) {
+				if !defined[pair[0].Value] {
+					pairs = append(pairs, pair)
+					defined[pair[0].Value] = true
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for _, pair := range mappingPairs(node) {
+		if pair[0].Value == key {
+			return resolveAlias(pair[1])
+		}
+	}
+	return nil
+}
+
+func mappingKeys(node *yaml.Node) []string {
+	keys := []string{}
+	for _, pair := range mappingPairs(node) {
+		keys = append(keys, pair[0].Value)
+	}
+	return keys
+}
+
+func sequenceValues(node *yaml.Node) []*yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return []*yaml.Node{}
+	}
+	values := []*yaml.Node{}
+	for _, value := range node.Content {
+		values = append(values, resolveAlias(value))
+	}
+	return values
+}
+
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.Tag {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+func typeMatches(node *yaml.Node, expected string) bool {
+	actual := nodeType(node)
+	return actual == expected || (expected == "number" && actual == "integer")
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func toSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -17,7 +17,8 @@ var PipelineCmd = &cobra.Command{
 		if curRepo := util.GetCurrentRepo(); curRepo != "" {
 			viper.SetDefault("repo", curRepo)
 		}
-		if !viper.IsSet("repo") {
+		// linting offline doesn't need a repository
+		if offline := cmd.Flags().Lookup("offline"); !viper.IsSet("repo") && (offline == nil || !offline.Changed) {
 			cobra.CheckErr("repo is not defined")
 		}
 	},
@@ -31,5 +32,7 @@ func init() {
 	PipelineCmd.AddCommand(LogsCmd)
 	PipelineCmd.AddCommand(VariablesCmd)
 	PipelineCmd.AddCommand(ReportCmd)
+	PipelineCmd.AddCommand(WatchCmd)
+	PipelineCmd.AddCommand(LintCmd)
 	PipelineCmd.PersistentFlags().StringP("repo", "R", "", "selected repository")
 }
@@ -3,10 +3,16 @@ package pipeline
 import (
 	"bb/api"
 	"bb/util"
+	"bufio"
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var RunCmd = &cobra.Command{
@@ -55,6 +61,22 @@ var RunCmd = &cobra.Command{
 			newpipeline.Target.RefName = ""
 		}
 
+		vars, _ := cmd.Flags().GetStringArray("var")
+		secureVars, _ := cmd.Flags().GetStringArray("secure-var")
+		newpipeline.Variables = append(parseRunVariables(vars, false), parseRunVariables(secureVars, true)...)
+		if selectedConfig != "" {
+			// the variables are declared in the configuration of the revision being run
+			revision := branch
+			if commit != "" {
+				revision = commit
+			} else if pullRequest != "" {
+				id, err := strconv.Atoi(strings.Trim(pullRequest, "#"))
+				cobra.CheckErr(err)
+				revision = (<-api.GetPr(repo, id)).Source.Commit.Hash
+			}
+			newpipeline.Variables = askPipelineVariables(repo, revision, selectedConfig, newpipeline.Variables)
+		}
+
 		pipeline := api.RunPipeline(repo, newpipeline)
 
 		if pipeline.State.Result.Name == "" {
@@ -79,10 +101,124 @@ var RunCmd = &cobra.Command{
 }
 
 func init() {
-	RunCmd.Flags().StringP("select", "s", "", "select which pipeline definition to run")
+	RunCmd.Flags().StringP("select", "s", "", "select which custom pipeline definition to run")
+	RunCmd.RegisterFlagCompletionFunc("select", func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		branch, _ := comd.Flags().GetString("branch")
+		if branch == "" && len(args) > 0 {
+			branch = args[0]
+		}
+		opt := []string{}
+		content, _, err := readPipelinesConfig(util.GetCurrentRepo(), branch)
+		if err != nil {
+			return opt, cobra.ShellCompDirectiveNoFileComp
+		}
+		pipelines, _ := customPipelines(content)
+		for _, pipeline := range pipelines {
+			opt = append(opt, pipeline.Name)
+		}
+		return opt, cobra.ShellCompDirectiveNoFileComp
+	})
+	RunCmd.Flags().StringArray("var", []string{}, `set a variable for the pipeline. Variables must be in the format KEY=VALUE. Multiple of these options can be given
+	variables declared by a custom pipeline that are not given are prompted`)
+	RunCmd.Flags().StringArray("secure-var", []string{}, "set a secured variable for the pipeline. Variables must be in the format KEY=VALUE")
 	RunCmd.Flags().StringP("commit", "c", "", "run pipeline on branch for specific commit")
 	RunCmd.Flags().StringP("branch", "b", "", "run pipeline for specific branch")
 	RunCmd.Flags().StringP("pull-request", "p", "", "run pipeline for a specific pull-request")
 	// TODO can we choose which step to log ?
 	// LogsCmd.Flags().BoolP("tail", "t", false, "tail logs of a running pipeline step")
 }
+
+func parseRunVariables(values []string, secure bool) []api.PipelineVariableBody {
+	varRegex := regexp.MustCompile(`([^=]+)=(.*)`)
+	variables := []api.PipelineVariableBody{}
+	for _, v := range values {
+		keyVal := varRegex.FindStringSubmatch(v)
+		if len(keyVal) != 3 {
+			cobra.CheckErr(fmt.Sprintf("Variable \"%s\" must be in the format \"KEY=VALUE\"", v))
+		}
+		variables = append(variables, api.PipelineVariableBody{Key: keyVal[1], Value: keyVal[2], Secured: secure})
+	}
+	return variables
+}
+
+/* Prompts for the variables declared by the custom pipeline that were not given. Defaults are used when stdin is not a terminal */
+func askPipelineVariables(repo string, revision string, name string, given []api.PipelineVariableBody) []api.PipelineVariableBody {
+	content, exact, err := readPipelinesConfig(repo, revision)
+	if err != nil {
+		return given
+	}
+	if !exact {
+		util.Printf("\033[1;33mWarning:\033[m could not read %s at %s, using the one of the working tree\n", pipelinesConfigFile, revision)
+	}
+	pipelines, err := customPipelines(content)
+	if err != nil {
+		util.Printf("\033[1;33mWarning:\033[m could not read the variables of '%s': %s\n", name, err)
+		return given
+	}
+	var selected *customPipeline
+	for i := range pipelines {
+		if pipelines[i].Name == name {
+			selected = &pipelines[i]
+		}
+	}
+	if selected == nil && !exact {
+		// the custom pipeline may only exist at the revision being run, bitbucket validates it
+		return given
+	} else if selected == nil {
+		cobra.CheckErr(fmt.Sprintf("Custom pipeline '%s' not found in %s", name, pipelinesConfigFile))
+	}
+
+	isGiven := map[string]bool{}
+	for _, v := range given {
+		isGiven[v.Key] = true
+	}
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	scanner := bufio.NewScanner(os.Stdin)
+	for _, variable := range selected.Variables {
+		if isGiven[variable.Name] {
+			continue
+		}
+		value := variable.Default
+		if interactive {
+			value = askVariable(scanner, variable)
+		} else if value == "" {
+			cobra.CheckErr(fmt.Sprintf("Variable '%s' has no default, set it with --var %s=VALUE", variable.Name, variable.Name))
+		}
+		given = append(given, api.PipelineVariableBody{Key: variable.Name, Value: value})
+	}
+	return given
+}
+
+func askVariable(scanner *bufio.Scanner, variable pipelineVariable) string {
+	for {
+		fmt.Printf("? \033[1;35m%s\033[m", variable.Name)
+		if variable.Description != "" {
+			fmt.Printf(" \033[37m(%s)\033[m", variable.Description)
+		}
+		if len(variable.AllowedValues) > 0 {
+			fmt.Printf(" [%s]", strings.Join(variable.AllowedValues, "/"))
+		}
+		if variable.Default != "" {
+			fmt.Printf(" \033[37mdefault: %s\033[m", variable.Default)
+		}
+		fmt.Print(" ")
+		if !scanner.Scan() {
+			cobra.CheckErr(fmt.Sprintf("No value given for variable '%s'", variable.Name))
+		}
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" {
+			value = variable.Default
+		}
+		if len(variable.AllowedValues) == 0 && value != "" {
+			return value
+		}
+		for _, allowed := range variable.AllowedValues {
+			if value == allowed {
+				return value
+			}
+		}
+		if len(variable.AllowedValues) > 0 {
+			util.Printf("\033[1;31m'%s' is not one of the allowed values\033[m\n", value)
+		}
+	}
+}
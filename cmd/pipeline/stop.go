@@ -23,7 +23,7 @@ var StopCmd = &cobra.Command{
 			branch, err := util.GetCurrentBranch()
 			cobra.CheckErr(err)
 			// retrieve id of pr for current branch
-			pr := <-api.GetPrList(repo, []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, "", "", branch, "", 1, false, false)
+			pr := <-api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, Source: branch})
 			if pr.ID == 0 {
 				cobra.CheckErr("No pr found for this branch")
 			}
@@ -0,0 +1,216 @@
+package pipeline
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+const maxPollFailures = 5 // consecutive failed updates before giving up
+
+var WatchCmd = &cobra.Command{
+	Use:   "watch [ID]",
+	Short: "Watch a pipeline until it completes",
+	Long: `Redraws the state and duration of every step until the pipeline completes, following the log of the running step.
+	Exits with 0 when the pipeline is successful, 1 when it fails or is stopped, 2 when it's paused on a manual step and 3 when it can't be read anymore.
+	If no ID is given the latest pipeline of the current branch is used`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		branch, _ := cmd.Flags().GetString("target")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		logLines, _ := cmd.Flags().GetInt("lines")
+
+		var id int
+		var err error
+		if len(args) == 0 {
+			if branch == "" {
+				branch, err = util.GetCurrentBranch()
+				cobra.CheckErr(err)
+			}
+			pipeline := <-api.GetPipelineList(repo, 1, branch)
+			if pipeline.BuildNumber == 0 {
+				cobra.CheckErr(fmt.Sprintf("No pipelines found for target branch: '%s'", branch))
+			}
+			id = pipeline.BuildNumber
+		} else {
+			id, err = strconv.Atoi(strings.Trim(args[0], "#"))
+			cobra.CheckErr(err)
+		}
+
+		pipelineId := fmt.Sprintf("%d", id)
+		offsets := map[string]int{}
+		tail := []string{}
+		tailStep := ""
+		drawnLines := 0
+		lastStates := ""
+		failures := 0
+		for {
+			// errors while polling are retried, they don't mean the pipeline failed
+			pipeline, err := api.ReadPipeline(repo, pipelineId)
+			var steps []api.PipelineStep
+			if err == nil {
+				steps, err = api.ReadPipelineSteps(repo, pipelineId)
+			}
+			if err != nil {
+				if failures++; failures >= maxPollFailures {
+					util.Printf("\033[1;31m✗\033[m Could not read pipeline \033[1;32m#%d\033[m: %s\n", id, err)
+					os.Exit(3)
+				}
+				time.Sleep(interval)
+				continue
+			}
+			failures = 0
+
+			var running *api.PipelineStep
+			for i := range steps {
+				if steps[i].State.Stage.Name == "RUNNING" {
+					running = &steps[i]
+				}
+			}
+			if running != nil && logLines > 0 && util.ColorEnabled() {
+				if running.UUID != tailStep {
+					tail, tailStep = []string{}, running.UUID
+				}
+				if log, err := api.ReadPipelineStepLog(repo, pipelineId, running.UUID, offsets[running.UUID]); err == nil {
+					offsets[running.UUID] += len(log)
+					tail = appendLogTail(tail, log, logLines)
+				}
+			}
+
+			if util.ColorEnabled() {
+				util.Redraw(renderWatch(pipeline, steps, running, visibleLogTail(tail, logLines)), &drawnLines)
+			} else if states := renderSteps(steps, false); states != lastStates {
+				// only print changes when the output is not a terminal
+				fmt.Print(states + "\n")
+				lastStates = states
+			}
+
+			state := pipelineState(pipeline)
+			switch {
+			case pipeline.State.Name == "COMPLETED" && state == "SUCCESSFUL":
+				util.Printf("Pipeline \033[1;32m#%d\033[m finished as %s %s\n", id, util.FormatPipelineStatus(state), strings.ToLower(state))
+				return
+			case pipeline.State.Name == "COMPLETED":
+				util.Printf("Pipeline \033[1;32m#%d\033[m finished as %s %s\n", id, util.FormatPipelineStatus(state), strings.ToLower(state))
+				os.Exit(1)
+			case pipeline.State.Stage.Name == "PAUSED" || pipeline.State.Name == "PAUSED":
+				util.Printf("Pipeline \033[1;32m#%d\033[m is paused waiting on a manual step\n", id)
+				os.Exit(2)
+			}
+			time.Sleep(interval)
+		}
+	},
+}
+
+func init() {
+	WatchCmd.Flags().String("target", "", "watch the latest pipeline of a branch")
+	WatchCmd.RegisterFlagCompletionFunc("target", util.BranchCompletion)
+	WatchCmd.Flags().Duration("interval", 3*time.Second, "time between updates")
+	WatchCmd.Flags().IntP("lines", "n", 10, "number of log lines of the running step to show. Use 0 to hide the log")
+}
+
+func pipelineState(pipeline api.Pipeline) string {
+	if pipeline.State.Result.Name != "" {
+		return pipeline.State.Result.Name
+	}
+	return pipeline.State.Name
+}
+
+func stepState(step api.PipelineStep) string {
+	if step.State.Result.Name != "" {
+		return step.State.Result.Name
+	} else if step.State.Stage.Name != "" {
+		return step.State.Stage.Name
+	}
+	return step.State.Name
+}
+
+/* Returns the duration of a step, counting from its start while it's running */
+func stepDuration(step api.PipelineStep) time.Duration {
+	if step.DurationInSeconds == 0 && step.State.Stage.Name == "RUNNING" && !step.StartedOn.IsZero() {
+		return time.Since(step.StartedOn).Round(time.Second)
+	}
+	return time.Duration(step.DurationInSeconds) * time.Second
+}
+
+func renderSteps(steps []api.PipelineStep, durations bool) string {
+	var out strings.Builder
+	for _, step := range steps {
+		out.WriteString(fmt.Sprintf("  %s %s", util.FormatPipelineStatus(stepState(step)), step.Name))
+		if duration := stepDuration(step); durations && duration > 0 {
+			out.WriteString(fmt.Sprintf(" \033[37m%s\033[m", duration))
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+func renderWatch(pipeline api.Pipeline, steps []api.PipelineStep, running *api.PipelineStep, tail []string) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s \033[1;32m#%d\033[m ", util.FormatPipelineStatus(pipelineState(pipeline)), pipeline.BuildNumber))
+	if pipeline.Target.Source != "" {
+		out.WriteString(fmt.Sprintf("%s \033[1;34m[ %s → %s]\033[m", pipeline.Target.PullRequest.Title, pipeline.Target.Source, pipeline.Target.Destination))
+	} else {
+		out.WriteString(fmt.Sprintf("\033[1;34m[ %s ]\033[m", pipeline.Target.RefName))
+	}
+	if pipeline.DurationInSeconds > 0 {
+		out.WriteString(fmt.Sprintf(" \033[37m%s\033[m", time.Duration(pipeline.DurationInSeconds)*time.Second))
+	} else if !pipeline.CreatedOn.IsZero() {
+		out.WriteString(fmt.Sprintf(" \033[37m%s\033[m", time.Since(pipeline.CreatedOn).Round(time.Second)))
+	}
+	out.WriteString("\n")
+	out.WriteString(renderSteps(steps, true))
+
+	if running != nil && len(tail) > 0 {
+		width := util.TerminalWidth()
+		out.WriteString(fmt.Sprintf("\033[37m── %s ──\033[m\n", running.Name))
+		for _, line := range tail {
+			// long lines would wrap and break the redraw
+			if runes := []rune(line); width > 4 && len(runes) > width-4 {
+				line = string(runes[:width-5]) + "…"
+			}
+			out.WriteString(fmt.Sprintf("  \033[37m%s\033[m\n", line))
+		}
+	}
+	return out.String()
+}
+
+/* Appends the lines of a log chunk to the tail. The last element is the line still being written, completed on the next chunk */
+func appendLogTail(tail []string, chunk string, n int) []string {
+	if chunk == "" {
+		return tail
+	}
+	chunk = ansiRegex.ReplaceAllString(strings.ReplaceAll(chunk, "\r", ""), "")
+	lines := strings.Split(chunk, "\n")
+	if len(tail) > 0 {
+		tail[len(tail)-1] += lines[0]
+		lines = lines[1:]
+	}
+	tail = append(tail, lines...)
+	if len(tail) > n+1 {
+		tail = tail[len(tail)-n-1:]
+	}
+	return tail
+}
+
+/* Returns the last n lines of the tail, leaving out the line still being written when it's empty */
+func visibleLogTail(tail []string, n int) []string {
+	if len(tail) > 0 && tail[len(tail)-1] == "" {
+		tail = tail[:len(tail)-1]
+	}
+	if len(tail) > n {
+		tail = tail[len(tail)-n:]
+	}
+	return tail
+}
@@ -0,0 +1,226 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ActivityCmd = &cobra.Command{
+	Use:   "activity [ID]",
+	Short: "Show the activity timeline of a pull request",
+	Long: `Show a chronological timeline of a pull request with pushed commits, title and target changes, reviews, comments and merges.
+	Use --since with a date, a duration or "me" to show only what changed since your last action on the pull request.
+	If no ID is given the pull request of the current branch is used`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+
+		activityChannel := api.GetPrActivity(repo, id)
+		pr := <-api.GetPr(repo, id)
+		events := buildTimeline(pr, <-activityChannel)
+
+		since, _ := cmd.Flags().GetString("since")
+		var sinceDate time.Time
+		if since == "me" || since == "@me" {
+			user := api.GetUser()
+			for _, event := range events {
+				if event.Actor.UUID == user.UUID {
+					sinceDate = event.Date
+				}
+			}
+		} else {
+			var err error
+			sinceDate, err = parseDateFlag(since)
+			cobra.CheckErr(err)
+		}
+
+		util.Printf("\n%s %s\033[1;32m#%d\033[m \033[1;37m%s\033[m  \033[1;34m[ %s → %s]\033[m\n\n", util.FormatPrState(pr.State), util.FormatPrDraft(pr.Draft), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name)
+		count := 0
+		for _, event := range events {
+			if !event.Date.After(sinceDate) {
+				continue
+			}
+			util.Printf("  %s \033[37m%-16s\033[m \033[33m%s\033[m %s\n", event.Icon, util.TimeAgo(event.Date), event.Actor.DisplayName, event.Text)
+			count++
+		}
+		if count == 0 && sinceDate.IsZero() {
+			util.Printf("  \033[37mNo activity\033[m\n")
+		} else if count == 0 {
+			util.Printf("  \033[37mNo activity since %s\033[m\n", sinceDate.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Println()
+	},
+}
+
+func init() {
+	ActivityCmd.Flags().String("since", "", `show only activity after a date (ex: "2024-01-31"), a duration ago (ex: "3d", "12h") or "me" for your last action`)
+	ActivityCmd.RegisterFlagCompletionFunc("since", func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"me", "1d", "1w"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+type timelineEvent struct {
+	Date  time.Time
+	Actor api.User
+	Icon  string
+	Text  string
+}
+
+/* Converts the activity log into events sorted by date. Updates are snapshots of the pull request so changes are found by comparing each one with the previous */
+func buildTimeline(pr api.PullRequest, activity []api.PrActivity) []timelineEvent {
+	events := []timelineEvent{}
+	updates := []api.PrActivityUpdate{}
+	// the api doesn't register when a review is withdrawn so it's inferred from the current state of the participants
+	approved, reviewState := map[string]bool{}, map[string]string{}
+	for _, participant := range pr.Participants {
+		approved[participant.User.UUID] = participant.Approved
+		reviewState[participant.User.UUID] = participant.State
+	}
+	lastApproval, lastChangesRequest := map[string]int{}, map[string]int{}
+
+	for _, entry := range activity {
+		switch {
+		case entry.Update != nil:
+			updates = append(updates, *entry.Update)
+		case entry.Approval != nil:
+			lastApproval[entry.Approval.User.UUID] = len(events)
+			events = append(events, timelineEvent{entry.Approval.Date, entry.Approval.User, "\033[1;32m✓\033[m", "approved"})
+		case entry.ChangesRequested != nil:
+			lastChangesRequest[entry.ChangesRequested.User.UUID] = len(events)
+			events = append(events, timelineEvent{entry.ChangesRequested.Date, entry.ChangesRequested.User, "\033[1;31m✗\033[m", "requested changes"})
+		case entry.Comment != nil && !entry.Comment.Deleted:
+			events = append(events, timelineEvent{entry.Comment.CreatedOn, entry.Comment.User, "\033[36m»\033[m", describeComment(*entry.Comment)})
+		}
+	}
+	for uuid, i := range lastApproval {
+		if !approved[uuid] {
+			events[i].Text += " \033[37m(withdrawn)\033[m"
+		}
+	}
+	for uuid, i := range lastChangesRequest {
+		if reviewState[uuid] != "changes_requested" {
+			events[i].Text += " \033[37m(withdrawn)\033[m"
+		}
+	}
+
+	// activity comes newest first
+	sort.SliceStable(updates, func(i, j int) bool { return updates[i].Date.Before(updates[j].Date) })
+	for i, update := range updates {
+		actor := update.Author
+		if i == 0 {
+			text := "opened the pull request"
+			if update.Draft {
+				text += " as a draft"
+			}
+			events = append(events, timelineEvent{update.Date, actor, "\033[1;34m●\033[m", text})
+			continue
+		}
+		previous := updates[i-1]
+		changes := describeUpdate(previous, update)
+		if update.State != previous.State && !strings.EqualFold(update.State, string(api.OPEN)) && pr.ClosedBy.UUID != "" {
+			actor = pr.ClosedBy
+		}
+		for _, change := range changes {
+			events = append(events, timelineEvent{update.Date, actor, change.Icon, change.Text})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+	return events
+}
+
+/* Lists what changed between two snapshots of the pull request */
+func describeUpdate(previous api.PrActivityUpdate, update api.PrActivityUpdate) []timelineEvent {
+	changes := []timelineEvent{}
+	add := func(icon string, format string, a ...any) {
+		changes = append(changes, timelineEvent{Icon: icon, Text: fmt.Sprintf(format, a...)})
+	}
+	if update.Source.Commit.Hash != previous.Source.Commit.Hash && update.Source.Commit.Hash != "" {
+		add("\033[1;34m●\033[m", "pushed new commits \033[37m%s → %s\033[m", shortHash(previous.Source.Commit.Hash), shortHash(update.Source.Commit.Hash))
+	}
+	if update.Title != previous.Title {
+		add("\033[1;34m●\033[m", "changed the title from \033[1;37m%s\033[m to \033[1;37m%s\033[m", previous.Title, update.Title)
+	}
+	if update.Destination.Branch.Name != previous.Destination.Branch.Name {
+		add("\033[1;34m●\033[m", "changed the target from \033[1;34m%s\033[m to \033[1;34m%s\033[m", previous.Destination.Branch.Name, update.Destination.Branch.Name)
+	}
+	if update.Description != previous.Description {
+		add("\033[1;34m●\033[m", "updated the description")
+	}
+	if update.Draft != previous.Draft {
+		if update.Draft {
+			add("\033[1;34m●\033[m", "converted to draft")
+		} else {
+			add("\033[1;34m●\033[m", "marked as ready for review")
+		}
+	}
+	added, removed := []string{}, []string{}
+	for _, reviewer := range update.Reviewers {
+		if !containsUser(previous.Reviewers, reviewer) {
+			added = append(added, reviewer.DisplayName)
+		}
+	}
+	for _, reviewer := range previous.Reviewers {
+		if !containsUser(update.Reviewers, reviewer) {
+			removed = append(removed, reviewer.DisplayName)
+		}
+	}
+	if len(added) > 0 {
+		add("\033[1;34m●\033[m", "added reviewers %s", strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		add("\033[1;34m●\033[m", "removed reviewers %s", strings.Join(removed, ", "))
+	}
+	if update.State != previous.State {
+		switch api.PrState(strings.ToLower(update.State)) {
+		case api.MERGED:
+			add("\033[1;35m⤵\033[m", "merged the pull request into \033[1;34m%s\033[m", update.Destination.Branch.Name)
+		case api.DECLINED:
+			add("\033[1;31m●\033[m", "declined the pull request")
+		case api.SUPERSEDED:
+			add("\033[37m●\033[m", "superseded the pull request")
+		case api.OPEN:
+			add("\033[1;34m●\033[m", "reopened the pull request")
+		}
+	}
+	return changes
+}
+
+func describeComment(comment api.PrComment) string {
+	text := "commented"
+	if comment.Parent != nil {
+		text = "replied to a comment"
+	}
+	if comment.Inline != nil {
+		text += " on \033[1;34m" + comment.Inline.Path
+		if comment.Inline.To != nil {
+			text += fmt.Sprintf(":%d", *comment.Inline.To)
+		} else if comment.Inline.From != nil {
+			text += fmt.Sprintf(":%d", *comment.Inline.From)
+		}
+		text += "\033[m"
+	}
+	content := strings.Join(strings.Fields(comment.Content.Raw), " ")
+	if len([]rune(content)) > 80 {
+		content = string([]rune(content)[:79]) + "…"
+	}
+	return fmt.Sprintf("%s \033[37m%s\033[m", text, content)
+}
+
+func containsUser(users []api.User, user api.User) bool {
+	for _, u := range users {
+		if u.UUID == user.UUID {
+			return true
+		}
+	}
+	return false
+}
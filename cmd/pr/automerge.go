@@ -0,0 +1,177 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const greenPollInterval = 15 * time.Second
+
+/* Waits until every status of the pull request passed and it has the required approvals */
+func waitForGreen(cmd *cobra.Command, repo string, pr api.PullRequest) error {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	required, _ := cmd.Flags().GetInt("approvals")
+	mergeChecks := mergeRestrictions(repo, pr.Destination.Branch.Name)
+	if !cmd.Flags().Changed("approvals") {
+		required = mergeChecks["require_approvals_to_merge"]
+	}
+	// at least one build must report, otherwise a pull request just pushed would be merged right away
+	requiredBuilds := 1
+	if builds := mergeChecks["require_passing_builds_to_merge"]; builds > requiredBuilds {
+		requiredBuilds = builds
+	}
+
+	start := time.Now()
+	headCommit := pr.Source.Commit.Hash
+	lastProgress := ""
+	drawnLines := 0
+	for {
+		statusesChannel := api.GetPrStatuses(repo, pr.ID)
+		current := <-api.GetPr(repo, pr.ID)
+		statuses := latestStatuses(<-statusesChannel)
+
+		if !strings.EqualFold(string(current.State), string(api.OPEN)) {
+			return fmt.Errorf("Pull request was %s while waiting", strings.ToLower(current.State.String()))
+		}
+		if current.Source.Commit.Hash != headCommit {
+			return fmt.Errorf("Pull request was updated while waiting (%s → %s)", shortHash(headCommit), shortHash(current.Source.Commit.Hash))
+		}
+
+		approvals, changesRequested := 0, 0
+		for _, participant := range current.Participants {
+			if participant.Approved {
+				approvals++
+			}
+			if participant.State == "changes_requested" {
+				changesRequested++
+			}
+		}
+
+		green := approvals >= required && changesRequested == 0
+		successful := 0
+		var progress strings.Builder
+		for _, status := range statuses {
+			progress.WriteString(fmt.Sprintf("  %s %s\n", util.FormatPipelineStatus(status.State), status.Name))
+			switch status.State {
+			case "SUCCESSFUL":
+				successful++
+			case "FAILED", "STOPPED":
+				util.Redraw(progress.String(), &drawnLines)
+				return fmt.Errorf("Status '%s' is %s, not merging", status.Name, status.State)
+			default:
+				green = false
+			}
+		}
+		if successful < requiredBuilds {
+			green = false
+			progress.WriteString(fmt.Sprintf("  Successful builds: \033[1;32m%d\033[m/%d\n", successful, requiredBuilds))
+		}
+		progress.WriteString(fmt.Sprintf("  Approvals: \033[1;32m%d\033[m/%d", approvals, required))
+		if changesRequested > 0 {
+			progress.WriteString(fmt.Sprintf(" \033[1;31m(%d requested changes)\033[m", changesRequested))
+		}
+		progress.WriteString("\n")
+
+		if util.ColorEnabled() {
+			util.Redraw(fmt.Sprintf("Waiting on pull request \033[1;32m#%d\033[m \033[37m(%s)\033[m\n%s", pr.ID, time.Since(start).Round(time.Second), progress.String()), &drawnLines)
+		} else if progress.String() != lastProgress {
+			// only print changes when the output is not a terminal
+			util.Redraw(fmt.Sprintf("Waiting on pull request #%d (%s)\n%s", pr.ID, time.Since(start).Round(time.Second), progress.String()), &drawnLines)
+		}
+		lastProgress = progress.String()
+
+		if green {
+			return nil
+		}
+		if timeout > 0 && time.Since(start)+greenPollInterval > timeout {
+			return fmt.Errorf("Timed out after %s waiting for pull request #%d", timeout, pr.ID)
+		}
+		time.Sleep(greenPollInterval)
+	}
+}
+
+/* Keeps only the most recent status of each name */
+func latestStatuses(statuses []api.CommitStatus) []api.CommitStatus {
+	latest := map[string]api.CommitStatus{}
+	for _, status := range statuses {
+		if previous, ok := latest[status.Name]; !ok || status.UpdatedOn.After(previous.UpdatedOn) {
+			latest[status.Name] = status
+		}
+	}
+	result := []api.CommitStatus{}
+	for _, status := range latest {
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+/* Returns the merge checks of the branch restrictions of the target branch, none if they can't be read */
+func mergeRestrictions(repo string, branch string) map[string]int {
+	restrictions, err := api.GetBranchRestrictions(repo, "")
+	if err != nil {
+		util.Printf("\033[1;33mWarning:\033[m could not read branch restrictions, use --approvals to set the required approvals\n")
+		return map[string]int{}
+	}
+	return branchRestrictionValues(restrictions, branch)
+}
+
+/* Returns the highest value of each kind of restriction that applies to branch */
+func branchRestrictionValues(restrictions []api.BranchRestriction, branch string) map[string]int {
+	values := map[string]int{}
+	for _, restriction := range restrictions {
+		if restriction.BranchMatchKind != "glob" {
+			continue // branching model restrictions are not supported
+		}
+		if matched, _ := path.Match(restriction.Pattern, branch); matched {
+			if value, ok := values[restriction.Kind]; !ok || restriction.Value > value {
+				values[restriction.Kind] = restriction.Value
+			}
+		}
+	}
+	return values
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+/* Runs the current command again in the background without the --background flag, with its output going to a log file */
+func runInBackground(ids []int) {
+	args := []string{}
+	for _, arg := range os.Args[1:] {
+		if arg != "--background" && arg != "--background=true" {
+			args = append(args, arg)
+		}
+	}
+	idNames := []string{}
+	for _, id := range ids {
+		idNames = append(idNames, fmt.Sprint(id))
+	}
+	logPath := filepath.Join(os.TempDir(), fmt.Sprintf("bb-merge-%s.log", strings.Join(idNames, "-")))
+	logFile, err := os.Create(logPath)
+	cobra.CheckErr(err)
+	defer logFile.Close()
+
+	executable, err := os.Executable()
+	cobra.CheckErr(err)
+	process := exec.Command(executable, args...)
+	process.Stdout = logFile
+	process.Stderr = logFile
+	util.DetachProcess(process)
+	cobra.CheckErr(process.Start())
+	util.Printf("Waiting in the background \033[37m(pid %d)\033[m, output in \033[1;34m%s\033[m\n", process.Process.Pid, logPath)
+}
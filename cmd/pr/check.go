@@ -0,0 +1,205 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	checkPassed = iota
+	checkWarning
+	checkPending
+	checkFailed
+)
+
+var CheckCmd = &cobra.Command{
+	Use:   "check [ID]",
+	Short: "Check if a pull request can be merged",
+	Long: `Check if a pull request can be merged by looking at conflicts, commits behind the target, builds, approvals, tasks and the merge checks of the branch restrictions.
+	Conflicts are found from the conflict markers of the diff or with a trial merge on the local repository with --local.
+	Exits with 0 when the pull request can be merged, 1 when it can't and 2 when only builds are still running.
+	If no ID is given the pull request of the current branch is used`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+		local, _ := cmd.Flags().GetBool("local")
+
+		statusesChannel := api.GetPrStatuses(repo, id)
+		tasksChannel := api.GetPrTasks(repo, id)
+		pr := <-api.GetPr(repo, id)
+		target := pr.Destination.Branch.Name
+
+		restrictions, err := api.GetBranchRestrictions(repo, "")
+		if err != nil {
+			util.Printf("\033[1;33mWarning:\033[m could not read branch restrictions: %s\n", err)
+		}
+		mergeChecks := branchRestrictionValues(restrictions, target)
+
+		util.Printf("%s %s\033[1;32m#%d\033[m \033[1;37m%s\033[m  \033[1;34m[ %s → %s]\033[m\n", util.FormatPrState(pr.State), util.FormatPrDraft(pr.Draft), pr.ID, pr.Title, pr.Source.Branch.Name, target)
+		result := checkPassed
+		report := func(state int, format string, a ...any) {
+			icons := map[int]string{checkPassed: "\033[1;32m✓\033[m", checkWarning: "\033[1;33m!\033[m", checkPending: "\033[1;33m●\033[m", checkFailed: "\033[1;31m✗\033[m"}
+			util.Printf("  "+icons[state]+" "+format+"\n", a...)
+			if state != checkWarning && state > result {
+				result = state
+			}
+		}
+
+		if !strings.EqualFold(string(pr.State), string(api.OPEN)) {
+			report(checkFailed, "Pull request is %s", strings.ToLower(pr.State.String()))
+		}
+		if pr.Draft {
+			report(checkFailed, "Pull request is a draft")
+		}
+
+		// CONFLICTS
+
+		var conflicts []string
+		var conflictErr error
+		if local {
+			conflicts, conflictErr = localConflicts(pr)
+		} else {
+			conflicts = diffConflicts(<-api.GetPrDiff(repo, id))
+		}
+		if conflictErr != nil {
+			report(checkFailed, "Could not check for conflicts: %s", conflictErr)
+		} else if len(conflicts) > 0 {
+			report(checkFailed, "%d files with conflicts: \033[1;34m%s\033[m", len(conflicts), strings.Join(conflicts, ", "))
+		} else {
+			report(checkPassed, "No conflicts")
+		}
+
+		// BEHIND TARGET
+
+		if pr.Source.Repository.FullName == "" || strings.EqualFold(pr.Source.Repository.FullName, pr.Destination.Repository.FullName) {
+			behind := len(<-api.GetCommitsBetween(repo, pr.Destination.Commit.Hash, pr.Source.Commit.Hash))
+			if allowed, ok := mergeChecks["require_commits_behind"]; ok && behind > allowed {
+				report(checkFailed, "%d commits behind \033[1;34m%s\033[m \033[37m(at most %d allowed)\033[m", behind, target, allowed)
+			} else if behind > 0 {
+				report(checkWarning, "%d commits behind \033[1;34m%s\033[m", behind, target)
+			} else {
+				report(checkPassed, "Up to date with \033[1;34m%s\033[m", target)
+			}
+		}
+
+		// BUILDS
+
+		statuses := latestStatuses(<-statusesChannel)
+		successful := 0
+		for _, status := range statuses {
+			switch status.State {
+			case "SUCCESSFUL":
+				successful++
+			case "FAILED", "STOPPED":
+				report(checkFailed, "Build \033[1;37m%s\033[m is %s", status.Name, strings.ToLower(status.State))
+			default:
+				report(checkPending, "Build \033[1;37m%s\033[m is %s", status.Name, strings.ToLower(status.State))
+			}
+		}
+		if required, ok := mergeChecks["require_passing_builds_to_merge"]; ok && successful < required {
+			report(checkFailed, "%d of %d required successful builds", successful, required)
+		} else if len(statuses) == 0 {
+			report(checkWarning, "No builds")
+		} else if successful == len(statuses) {
+			report(checkPassed, "%d successful builds", successful)
+		}
+
+		// REVIEWS
+
+		approvals, changesRequested := 0, 0
+		for _, participant := range pr.Participants {
+			if participant.Approved {
+				approvals++
+			}
+			if participant.State == "changes_requested" {
+				changesRequested++
+			}
+		}
+		if required := mergeChecks["require_approvals_to_merge"]; approvals < required {
+			report(checkFailed, "%d of %d required approvals", approvals, required)
+		} else if approvals == 0 {
+			report(checkWarning, "No approvals")
+		} else {
+			report(checkPassed, "%d approvals", approvals)
+		}
+		if required, ok := mergeChecks["require_default_reviewer_approvals_to_merge"]; ok {
+			report(checkWarning, "Requires %d approvals from default reviewers \033[37m(not checked)\033[m", required)
+		}
+		if _, ok := mergeChecks["require_no_changes_requested"]; ok && changesRequested > 0 {
+			report(checkFailed, "%d reviewers requested changes", changesRequested)
+		} else if changesRequested > 0 {
+			report(checkWarning, "%d reviewers requested changes", changesRequested)
+		}
+
+		// TASKS
+
+		unresolved := countUnresolvedTasks(<-tasksChannel)
+		if _, ok := mergeChecks["require_tasks_to_be_completed"]; ok && unresolved > 0 {
+			report(checkFailed, "%d unresolved tasks", unresolved)
+		} else if unresolved > 0 {
+			report(checkWarning, "%d unresolved tasks", unresolved)
+		} else {
+			report(checkPassed, "No unresolved tasks")
+		}
+
+		switch result {
+		case checkFailed:
+			util.Printf("\033[1;31mPull request can't be merged\033[m\n")
+			os.Exit(1)
+		case checkPending:
+			util.Printf("\033[1;33mPull request is waiting on builds\033[m\n")
+			os.Exit(2)
+		default:
+			util.Printf("\033[1;32mPull request can be merged\033[m\n")
+		}
+	},
+}
+
+func init() {
+	CheckCmd.Flags().Bool("local", false, "find conflicts with a trial merge on the local repository instead of the diff")
+}
+
+/* Returns the files of the diff with conflict markers, which bitbucket adds when the pull request doesn't merge cleanly */
+func diffConflicts(diff string) []string {
+	conflicts := []string{}
+	for _, file := range splitDiff(diff) {
+		if strings.Contains(file.Content, "\n+<<<<<<< destination:") {
+			conflicts = append(conflicts, file.Path)
+		}
+	}
+	return conflicts
+}
+
+/* Fetches both branches of the pull request and merges them without touching the worktree */
+func localConflicts(pr api.PullRequest) ([]string, error) {
+	fetchRef := func(repository string, branch string) (string, error) {
+		remoteName, err := util.EnsureRemote(repository)
+		if err != nil {
+			return "", err
+		}
+		if err := util.FetchBranch(remoteName, branch); err != nil {
+			return "", err
+		}
+		return remoteName + "/" + branch, nil
+	}
+	sourceRepo := pr.Source.Repository.FullName
+	if sourceRepo == "" {
+		sourceRepo = viper.GetString("repo")
+	}
+	target, err := fetchRef(viper.GetString("repo"), pr.Destination.Branch.Name)
+	if err != nil {
+		return nil, err
+	}
+	source, err := fetchRef(sourceRepo, pr.Source.Branch.Name)
+	if err != nil {
+		return nil, err
+	}
+	return util.TrialMerge(target, source)
+}
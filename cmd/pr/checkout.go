@@ -0,0 +1,97 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var CheckoutCmd = &cobra.Command{
+	Use:     "checkout ID",
+	Aliases: []string{"co"},
+	Short:   "Check out the source branch of a pull request locally [co]",
+	Long: `Fetch the source branch of a pull request and switch to it.
+	A local branch tracking the source branch is created, or fast-forwarded if it already exists.
+	If a local branch with the same name tracks something else, the branch is named pr-ID-BRANCH instead.
+	For pull requests from forks a remote named after the fork workspace is added`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+		detach, _ := cmd.Flags().GetBool("detach")
+		force, _ := cmd.Flags().GetBool("force")
+		localBranch, _ := cmd.Flags().GetString("branch")
+
+		pr := <-api.GetPr(repo, id)
+		sourceBranch := pr.Source.Branch.Name
+		sourceRepo := pr.Source.Repository.FullName
+		if sourceRepo == "" {
+			cobra.CheckErr(fmt.Sprintf("The source repository of pull request #%d is no longer available", id))
+		}
+
+		remoteName := "origin"
+		if !strings.EqualFold(sourceRepo, repo) {
+			var err error
+			remoteName, err = util.EnsureRemote(sourceRepo)
+			cobra.CheckErr(err)
+		}
+
+		util.Printf("Fetching \033[1;34m%s\033[m from \033[1;36m%s\033[m...\n", sourceBranch, remoteName)
+		cobra.CheckErr(util.FetchBranch(remoteName, sourceBranch))
+		remoteRef := remoteName + "/" + sourceBranch
+
+		if detach {
+			cobra.CheckErr(util.CheckoutDetached(remoteRef))
+			util.Printf("Checked out pull request \033[1;32m#%d\033[m at \033[1;34m%s\033[m (detached)\n", id, remoteRef)
+			return
+		}
+
+		if localBranch == "" {
+			localBranch = sourceBranch
+			if util.RefExists("refs/heads/"+localBranch) && util.GetUpstream(localBranch) != remoteRef && !force {
+				// an unrelated branch already uses this name
+				localBranch = fmt.Sprintf("pr-%d-%s", id, sourceBranch)
+			}
+		}
+
+		if !util.RefExists("refs/heads/" + localBranch) {
+			cobra.CheckErr(util.CheckoutNewBranch(localBranch, remoteRef))
+			util.Printf("Switched to new branch \033[1;34m%s\033[m tracking \033[1;36m%s\033[m\n", localBranch, remoteRef)
+			return
+		}
+
+		localHash, err := util.GetRefHash("refs/heads/" + localBranch)
+		cobra.CheckErr(err)
+		remoteHash, err := util.GetRefHash(remoteRef)
+		cobra.CheckErr(err)
+		if localHash != remoteHash && !util.IsAncestor(localBranch, remoteRef) && !force {
+			cobra.CheckErr(fmt.Sprintf("Branch '%s' has diverged from '%s'. Use --force to reset it", localBranch, remoteRef))
+		}
+
+		current, _ := util.GetCurrentBranch()
+		if current != localBranch {
+			if localHash != remoteHash {
+				cobra.CheckErr(util.SetBranchRef(localBranch, remoteRef))
+			}
+			cobra.CheckErr(util.CheckoutBranch(localBranch, force))
+		} else if localHash != remoteHash {
+			cobra.CheckErr(util.UpdateCurrentBranch(remoteRef, force))
+		}
+		if localHash != remoteHash {
+			util.Printf("Switched to branch \033[1;34m%s\033[m and updated it to \033[1;36m%s\033[m\n", localBranch, remoteRef)
+		} else {
+			util.Printf("Switched to branch \033[1;34m%s\033[m\n", localBranch)
+		}
+	},
+}
+
+func init() {
+	CheckoutCmd.Flags().Bool("detach", false, "check out the pull request head in detached HEAD mode")
+	CheckoutCmd.Flags().BoolP("force", "f", false, "reset the existing local branch to the pull request head, discarding local changes")
+	CheckoutCmd.Flags().StringP("branch", "b", "", "name of the local branch. Defaults to the source branch name")
+}
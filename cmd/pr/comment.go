@@ -0,0 +1,118 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var CommentCmd = &cobra.Command{
+	Use:   "comment [ID] [MESSAGE]",
+	Short: "Add, reply to, edit or delete comments on a pull request",
+	Long: `Add a comment to a pull request.
+	If no ID is given the pull request of the current branch is used.
+	The message is taken from the argument, from stdin when it is "-" or written in your EDITOR otherwise.
+	Use --file and --line to comment on a specific line of the changes, by default on the new version of the file`,
+	Example: `comment 42 "looks good"
+	comment --file main.go --line 10 "this can be removed"
+	comment --reply-to 1234 - < answer.md`,
+	Args:              cobra.MaximumNArgs(2),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		file, _ := cmd.Flags().GetString("file")
+		line, _ := cmd.Flags().GetInt("line")
+		oldSide, _ := cmd.Flags().GetBool("old")
+		replyTo, _ := cmd.Flags().GetInt("reply-to")
+		editId, _ := cmd.Flags().GetInt("edit")
+		deleteId, _ := cmd.Flags().GetInt("delete")
+
+		idArgs, message := args, ""
+		if len(args) == 2 {
+			idArgs, message = args[:1], args[1]
+		} else if len(args) == 1 {
+			if _, err := strconv.Atoi(strings.TrimPrefix(args[0], "#")); err != nil {
+				idArgs, message = []string{}, args[0]
+			}
+		}
+		id := getPrId(repo, idArgs)
+
+		if line != 0 && file == "" {
+			cobra.CheckErr("--line requires --file")
+		}
+
+		if deleteId != 0 {
+			comment := getOwnComment(repo, id, deleteId)
+			util.Printf("%s\n", comment.Content.Raw)
+			if !util.AskYesNo(bufio.NewScanner(os.Stdin), fmt.Sprintf("Delete comment #%d ?", deleteId)) {
+				return
+			}
+			api.DeletePrComment(repo, id, deleteId)
+			util.Printf("Comment #%d \033[1;31mDeleted\033[m\n", deleteId)
+			return
+		}
+
+		if editId != 0 {
+			comment := getOwnComment(repo, id, editId)
+			message = readCommentMessage(message, comment.Content.Raw)
+			comment = api.UpdatePrComment(repo, id, editId, message)
+			util.Printf("Comment #%d \033[1;34mUpdated\033[m\n  \033[37m%s\033[m\n", comment.Id, comment.Links.Html.Href)
+			return
+		}
+
+		body := api.CreateCommentBody{}
+		body.Content.Raw = readCommentMessage(message, "")
+		if file != "" {
+			body.Inline = &api.CommentInline{Path: file}
+			if line != 0 && oldSide {
+				body.Inline.From = &line
+			} else if line != 0 {
+				body.Inline.To = &line
+			}
+		}
+		if replyTo != 0 {
+			body.Parent = &api.CommentParent{Id: replyTo}
+		}
+		comment := api.PostPrComment(repo, id, body)
+		util.Printf("Comment #%d \033[1;32mAdded\033[m to pull request #%d\n  \033[37m%s\033[m\n", comment.Id, id, comment.Links.Html.Href)
+	},
+}
+
+func init() {
+	CommentCmd.Flags().String("file", "", "path of the file to comment on")
+	CommentCmd.Flags().Int("line", 0, "line of the file to comment on")
+	CommentCmd.Flags().Bool("old", false, "the line refers to the old version of the file (removed lines)")
+	CommentCmd.Flags().Int("reply-to", 0, "ID of the comment to reply to")
+	CommentCmd.Flags().Int("edit", 0, "ID of your comment to edit")
+	CommentCmd.Flags().Int("delete", 0, "ID of your comment to delete")
+	CommentCmd.MarkFlagsMutuallyExclusive("edit", "delete", "reply-to")
+	CommentCmd.MarkFlagsMutuallyExclusive("edit", "delete", "file")
+}
+
+/* Fetches a comment, failing if it wasn't written by the current user */
+func getOwnComment(repo string, id int, commentId int) api.PrComment {
+	comment := <-api.GetPrComment(repo, id, commentId)
+	if comment.User.AccountId != api.GetUser().AccountId {
+		cobra.CheckErr(fmt.Sprintf("Comment #%d was written by %s, only your own comments can be changed", commentId, comment.User.DisplayName))
+	}
+	return comment
+}
+
+func readCommentMessage(message string, initial string) string {
+	if message == "-" {
+		message = util.ReadBodyFile("-")
+	} else if message == "" {
+		message = util.ReadDescription(initial)
+	}
+	if strings.TrimSpace(message) == "" {
+		cobra.CheckErr("Empty comment, aborting")
+	}
+	return message
+}
@@ -0,0 +1,114 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var CommitsCmd = &cobra.Command{
+	Use:   "commits [ID]",
+	Short: "List commits of a pull request",
+	Long: `List the commits of a pull request with the Jira issue keys in their messages highlighted.
+	Use --issues to get a summary of the Jira issues referenced by the commits and their current status.
+	If no ID is given the pull request of the current branch is used`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+		issues, _ := cmd.Flags().GetBool("issues")
+		if issues && !viper.IsSet("jira_domain") {
+			cobra.CheckErr("jira domain is not defined")
+		}
+
+		prChannel := api.GetPr(repo, id)
+		commits := <-api.GetPrCommits(repo, id)
+		pr := <-prChannel
+		if len(commits) == 0 {
+			util.Printf("No commits on pull request \033[1;32m#%d\033[m\n", id)
+			return
+		}
+
+		re := regexp.MustCompile(api.JiraIssueKeyRegex)
+		keys := []string{}
+		keyCommits := map[string]int{}
+		for _, commit := range commits {
+			for _, key := range unique(re.FindAllString(commit.Message, -1)) {
+				if keyCommits[key] == 0 {
+					keys = append(keys, key)
+				}
+				keyCommits[key]++
+			}
+		}
+
+		if !issues {
+			for _, commit := range commits {
+				subject := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+				subject = re.ReplaceAllString(subject, "\033[1;32m$0\033[m")
+				util.Printf("\033[1;33m%s\033[m %s \033[33m%s\033[m \033[37m(%s)\033[m\n", shortHash(commit.Hash), subject, commitAuthor(commit), util.TimeAgo(commit.Date))
+			}
+			return
+		}
+
+		if len(keys) == 0 {
+			util.Printf("No Jira issues referenced on the commits of pull request \033[1;32m#%d\033[m\n", id)
+			return
+		}
+		// fetch all issues concurrently
+		channels := make([]<-chan api.JiraIssue, len(keys))
+		for i, key := range keys {
+			channels[i] = api.FindIssue(key)
+		}
+		branchKey := re.FindString(pr.Source.Branch.Name)
+		for i, key := range keys {
+			issue := <-channels[i]
+			plural := "s"
+			if keyCommits[key] == 1 {
+				plural = ""
+			}
+			if issue.Key == "" {
+				util.Printf("\033[1;31m?\033[m \033[1;32m%s\033[m \033[37mnot found\033[m", key)
+			} else {
+				util.Printf("%s \033[1;32m%s\033[m %s %s", util.FormatIssueStatus(issue.Fields.Status.Name), issue.Key, util.FormatIssueType(issue.Fields.Type.Name), issue.Fields.Summary)
+			}
+			util.Printf(" \033[37m(%d commit%s)\033[m", keyCommits[key], plural)
+			if key == branchKey {
+				util.Printf(" \033[1;34m[ branch ]\033[m")
+			}
+			fmt.Println()
+		}
+	},
+}
+
+func init() {
+	CommitsCmd.Flags().Bool("issues", false, "summarize the distinct Jira issues referenced by the commits with their current status")
+}
+
+/* Returns the name of the commit author, using the bitbucket user when the email is linked to one */
+func commitAuthor(commit api.Commit) string {
+	if commit.Author.User.DisplayName != "" {
+		return commit.Author.User.DisplayName
+	}
+	if i := strings.Index(commit.Author.Raw, " <"); i > 0 {
+		return commit.Author.Raw[:i]
+	}
+	return commit.Author.Raw
+}
+
+func unique(values []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,252 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff [ID]",
+	Short: "View the changes of a pull request",
+	Long: `View the changes of a pull request as a unified diff.
+	If no ID is given the pull request of the current branch is used.
+	Output is paged through "diff_pager" from your config (ex: delta), $PAGER or less.
+	When "diff_pager" is set the diff is passed to it without colors so it can do its own highlighting`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+		stat, _ := cmd.Flags().GetBool("stat")
+		nameOnly, _ := cmd.Flags().GetBool("name-only")
+		patch, _ := cmd.Flags().GetBool("patch")
+		noPager, _ := cmd.Flags().GetBool("no-pager")
+		filters, _ := cmd.Flags().GetStringArray("path")
+
+		if stat || nameOnly {
+			var output strings.Builder
+			added, removed, files := 0, 0, 0
+			for _, file := range <-api.GetPrDiffStat(repo, id) {
+				filePath := diffStatPath(file)
+				if !matchesPathFilters(filePath, filters) {
+					continue
+				}
+				files++
+				added += file.LinesAdded
+				removed += file.LinesRemoved
+				if nameOnly {
+					output.WriteString(filePath + "\n")
+				} else {
+					output.WriteString(formatDiffStat(file, filePath))
+				}
+			}
+			if stat {
+				output.WriteString(fmt.Sprintf(" %d files changed, \033[32m%d insertions(+)\033[m, \033[31m%d deletions(-)\033[m\n", files, added, removed))
+			}
+			printDiffOutput(output.String(), "", true)
+			return
+		}
+
+		var output strings.Builder
+		for _, file := range splitDiff(<-api.GetPrDiff(repo, id)) {
+			if matchesPathFilters(file.Path, filters) || matchesPathFilters(file.OldPath, filters) {
+				output.WriteString(file.Content)
+			}
+		}
+
+		if patch {
+			fmt.Print(output.String())
+			return
+		}
+		pager := viper.GetString("diff_pager")
+		if noPager {
+			printDiffOutput(colorizeDiff(output.String()), "", true)
+		} else if pager != "" {
+			printDiffOutput(output.String(), pager, false)
+		} else {
+			printDiffOutput(colorizeDiff(output.String()), "", false)
+		}
+	},
+}
+
+func init() {
+	DiffCmd.Flags().Bool("stat", false, "show a summary of the changed files")
+	DiffCmd.Flags().Bool("name-only", false, "show only the names of the changed files")
+	DiffCmd.Flags().Bool("patch", false, "output the raw diff, suitable for git apply")
+	DiffCmd.Flags().Bool("no-pager", false, "don't page the output")
+	DiffCmd.Flags().StringArrayP("path", "p", []string{}, `show only files matching the path. Multiple of these options can be given
+	the path can be a directory or a pattern such as "src/*.go"`)
+	DiffCmd.MarkFlagsMutuallyExclusive("stat", "name-only", "patch")
+}
+
+type fileDiff struct {
+	Path    string
+	OldPath string
+	Content string
+}
+
+var diffHeaderRegex = regexp.MustCompile(`^diff --git a/(.*) b/(.*)$`)
+
+/* Splits a unified diff into the diff of each file */
+func splitDiff(diff string) []fileDiff {
+	files := []fileDiff{}
+	var current *fileDiff
+	var content strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if match := diffHeaderRegex.FindStringSubmatch(strings.TrimRight(line, "\n")); match != nil {
+			if current != nil {
+				current.Content = content.String()
+				files = append(files, *current)
+			}
+			current = &fileDiff{OldPath: match[1], Path: match[2]}
+			content.Reset()
+		}
+		if current != nil {
+			content.WriteString(line)
+		}
+	}
+	if current != nil {
+		current.Content = content.String()
+		files = append(files, *current)
+	}
+	return files
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+/* Returns up to n lines of the diff ending on the given line of the new file, or of the old file if oldSide is set */
+func diffContext(file fileDiff, line int, oldSide bool, n int) []string {
+	oldLine, newLine := 0, 0
+	inHunk := false
+	window := []string{}
+	for _, text := range strings.Split(file.Content, "\n") {
+		if match := hunkHeaderRegex.FindStringSubmatch(text); match != nil {
+			oldLine, _ = strconv.Atoi(match[1])
+			newLine, _ = strconv.Atoi(match[2])
+			inHunk = true
+			window = window[:0]
+			continue
+		}
+		if !inHunk || text == "" || strings.HasPrefix(text, "\\") {
+			continue // file header or "no newline at end of file"
+		}
+		current := 0
+		switch text[0] {
+		case '+':
+			if !oldSide {
+				current = newLine
+			}
+			newLine++
+		case '-':
+			if oldSide {
+				current = oldLine
+			}
+			oldLine++
+		default:
+			current = newLine
+			if oldSide {
+				current = oldLine
+			}
+			oldLine++
+			newLine++
+		}
+		window = append(window, text)
+		if len(window) > n {
+			window = window[1:]
+		}
+		if current == line {
+			return window
+		}
+	}
+	return nil
+}
+
+func colorizeDiff(diff string) string {
+	var output strings.Builder
+	inHeader := false
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		text := strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(text, "diff --git"):
+			inHeader = true
+			output.WriteString(fmt.Sprintf("\033[1;37m%s\033[m\n", text))
+		case strings.HasPrefix(text, "@@"):
+			inHeader = false
+			output.WriteString(fmt.Sprintf("\033[36m%s\033[m\n", text))
+		case inHeader:
+			output.WriteString(fmt.Sprintf("\033[1;37m%s\033[m\n", text))
+		case strings.HasPrefix(text, "+"):
+			output.WriteString(fmt.Sprintf("\033[32m%s\033[m\n", text))
+		case strings.HasPrefix(text, "-"):
+			output.WriteString(fmt.Sprintf("\033[31m%s\033[m\n", text))
+		default:
+			output.WriteString(line)
+		}
+	}
+	return output.String()
+}
+
+func printDiffOutput(output string, pager string, noPager bool) {
+	if !util.ColorEnabled() {
+		output = regexp.MustCompile(`\x1b\[[0-9;]*m`).ReplaceAllString(output, "")
+	}
+	if noPager {
+		fmt.Print(output)
+	} else {
+		util.OpenInPager(output, pager)
+	}
+}
+
+func diffStatPath(file api.DiffStat) string {
+	if file.New != nil {
+		return file.New.Path
+	} else if file.Old != nil {
+		return file.Old.Path
+	}
+	return ""
+}
+
+func formatDiffStat(file api.DiffStat, filePath string) string {
+	if file.Status == "renamed" && file.Old != nil {
+		filePath = fmt.Sprintf("%s → %s", file.Old.Path, filePath)
+	}
+	changes := file.LinesAdded + file.LinesRemoved
+	added, removed := file.LinesAdded, file.LinesRemoved
+	if changes > 50 {
+		// scale the bar to a maximum width
+		added, removed = added*50/changes, removed*50/changes
+	}
+	conflict := ""
+	if strings.Contains(file.Status, "conflict") {
+		conflict = " \033[1;31m(conflict)\033[m"
+	}
+	return fmt.Sprintf(" %-60s | %4d \033[32m%s\033[31m%s\033[m%s\n", filePath, changes, strings.Repeat("+", added), strings.Repeat("-", removed), conflict)
+}
+
+/* Returns true if filePath is one of the filters, inside one of them or matches one as a pattern */
+func matchesPathFilters(filePath string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		filter = strings.TrimPrefix(filter, "./")
+		if filePath == filter || strings.HasPrefix(filePath, strings.TrimSuffix(filter, "/")+"/") {
+			return true
+		}
+		if matched, _ := path.Match(filter, filePath); matched {
+			return true
+		}
+		if matched, _ := path.Match(filter, path.Base(filePath)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -3,10 +3,10 @@ package pr
 import (
 	"bb/api"
 	"bb/util"
+	"bufio"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -14,83 +14,100 @@ import (
 )
 
 var EditCmd = &cobra.Command{
-	Use:   "edit ID",
+	Use:   "edit [ID]",
 	Short: "Edit details of a pull request",
 	Long: `Allows edits to an existing pull request
-	If no options are given to edit title or description it will open your EDITOR to write any changes to them.
-	By default title is on first line and description on the lines bellow`,
-	Args: cobra.MaximumNArgs(1),
-	ValidArgsFunction: func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		var opt = []string{}
-		for pr := range api.GetPrList(util.GetCurrentRepo(), []string{string(api.OPEN)}, "", "", "", "", 1, false, false) {
-			opt = append(opt, fmt.Sprint(pr.ID))
-		}
-		return opt, cobra.ShellCompDirectiveDefault
-	},
+	If no options are given to edit it will open your EDITOR to write any changes to title and description.
+	By default title is on first line and description on the lines bellow.
+	The changes are shown for confirmation before updating the pull request, use --yes to skip it`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
 	Run: func(cmd *cobra.Command, args []string) {
 		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+		assumeYes, _ := cmd.Flags().GetBool("yes")
+		bodyFile, _ := cmd.Flags().GetString("body-file")
+		if bodyFile == "-" && !assumeYes {
+			cobra.CheckErr("--yes is required when reading the description from stdin")
+		}
 
-		var id int
-		var err error
-		if len(args) == 0 {
-			branch, err := util.GetCurrentBranch()
-			cobra.CheckErr(err)
-			// retrieve id of pr for current branch
-			pr := <-api.GetPrList(repo, []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, "", "", branch, "", 1, false, false)
-			if pr.ID == 0 {
-				cobra.CheckErr("No pr found for this branch")
+		existingPr := <-api.GetPr(repo, id)
+		newpr := api.PrUpdateBody(existingPr)
+		changed := func(flags ...string) bool {
+			for _, flag := range flags {
+				if cmd.Flags().Changed(flag) {
+					return true
+				}
 			}
-			id = pr.ID // get the first one's ID
-		} else {
-			id, err = strconv.Atoi(args[0])
-			cobra.CheckErr(err)
+			return false
 		}
 
-		title, _ := cmd.Flags().GetString("title")
-		description, _ := cmd.Flags().GetString("body")
-		source, _ := cmd.Flags().GetString("source")
-		target, _ := cmd.Flags().GetString("target")
-		close_source, _ := cmd.Flags().GetBool("close_source")
-
 		// if no options given ask for what to change
-		existingPr := <-api.GetPr(repo, id)
-		if !cmd.Flags().Changed("title") && !cmd.Flags().Changed("body") {
-			title, description = readTitleAndDescription(existingPr)
+		if !changed("title", "body", "body-file", "source", "target", "close-source", "reviewer", "add-reviewer", "remove-reviewer") {
+			newpr.Title, newpr.Description = readTitleAndDescription(existingPr)
+			if newpr.Title == "" {
+				newpr.Title = existingPr.Title
+			}
 		}
-		if title == "" && !cmd.Flags().Changed("title") {
-			title = existingPr.Title
+		if changed("title") {
+			newpr.Title, _ = cmd.Flags().GetString("title")
 		}
-		if description == "" && !cmd.Flags().Changed("description") {
-			description = existingPr.Description
+		if changed("body") {
+			newpr.Description, _ = cmd.Flags().GetString("body")
+		} else if bodyFile != "" {
+			newpr.Description = util.ReadBodyFile(bodyFile)
 		}
-
-		if !cmd.Flags().Changed("close_source") {
-			close_source = existingPr.CloseSource
+		if changed("close-source") {
+			newpr.CloseSource, _ = cmd.Flags().GetBool("close-source")
 		}
 
-		newpr := api.CreatePullRequestBody{
-			Title:       title,
-			Description: description,
-			CloseSource: close_source,
-		}
-		if source == "" {
-			newpr.Source = nil
-		} else {
+		source, _ := cmd.Flags().GetString("source")
+		if source != "" && source != existingPr.Source.Branch.Name {
 			newpr.Source = &api.Branch{}
 			newpr.Source.Branch.Name = source
-		}
-		if target == "" {
-			newpr.Destination = nil
 		} else {
+			source = existingPr.Source.Branch.Name
+		}
+		target, _ := cmd.Flags().GetString("target")
+		if target != "" && target != existingPr.Destination.Branch.Name {
+			if target == source {
+				cobra.CheckErr("Target branch must be different from the source branch")
+			}
+			if !api.BranchExists(repo, target) {
+				cobra.CheckErr(fmt.Sprintf("Branch '%s' doesn't exist on %s", target, repo))
+			}
 			newpr.Destination = &api.Branch{}
 			newpr.Destination.Branch.Name = target
 		}
-		newpr.Reviewers = nil
+
+		reviewers := existingPr.Reviewers
+		reviewerChanges, _ := cmd.Flags().GetStringArray("reviewer")
+		added, _ := cmd.Flags().GetStringArray("add-reviewer")
+		removed, _ := cmd.Flags().GetStringArray("remove-reviewer")
+		for _, name := range added {
+			reviewerChanges = append(reviewerChanges, "+"+name)
+		}
+		for _, name := range removed {
+			reviewerChanges = append(reviewerChanges, "-"+name)
+		}
+		if len(reviewerChanges) > 0 {
+			candidates := util.LoadReviewerCandidates(repo, existingPr.Author.AccountId)
+			reviewers = editReviewers(existingPr.Reviewers, reviewerChanges, candidates, strings.Split(repo, "/")[0])
+		}
+		newpr.Reviewers = util.ReviewersBody(reviewers)
+
+		if !printPrChanges(existingPr, newpr, reviewers) {
+			util.Printf("Nothing to change on pull request \033[1;32m#%d\033[m\n", id)
+			return
+		}
+		if !assumeYes && !util.AskYesNo(bufio.NewScanner(os.Stdin), "Update the pull request?") {
+			return
+		}
 
 		pr := api.UpdatePr(repo, id, newpr)
 
-		fmt.Printf("\n%s \033[1;32m#%d\033[m \033[1;37m%s\033[m  \033[1;34m[ %s → %s]\033[m\n", util.FormatPrState(pr.State), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name)
-		fmt.Printf("\033[37m  opened by %s, %d comments, last updated: %s\033[m\n\n", pr.Author.Nickname, pr.CommentCount, util.TimeAgo(pr.UpdatedOn))
+		util.Printf("\n%s \033[1;32m#%d\033[m \033[1;37m%s\033[m  \033[1;34m[ %s → %s]\033[m\n", util.FormatPrState(pr.State), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name)
+		util.Printf("\033[37m  opened by %s, %d comments, last updated: %s\033[m\n\n", pr.Author.Nickname, pr.CommentCount, util.TimeAgo(pr.UpdatedOn))
 		if pr.Description != "" {
 			fmt.Printf("%s\n\n", pr.Description)
 		}
@@ -100,12 +117,88 @@ var EditCmd = &cobra.Command{
 func init() {
 	EditCmd.Flags().StringP("title", "t", "", "title for the pull request")
 	EditCmd.Flags().StringP("body", "b", "", "description for the pull request")
-	EditCmd.Flags().String("source", "", "source branch. Defaults to current branch")
-	EditCmd.Flags().String("target", "", "description for the pull request")
+	EditCmd.Flags().String("body-file", "", `read the description from a file. Use "-" to read from stdin`)
+	EditCmd.MarkFlagsMutuallyExclusive("body", "body-file")
+	EditCmd.Flags().String("source", "", "source branch")
+	EditCmd.Flags().String("target", "", "target branch")
 	EditCmd.RegisterFlagCompletionFunc("source", util.BranchCompletion)
 	EditCmd.RegisterFlagCompletionFunc("target", util.BranchCompletion)
-	EditCmd.Flags().BoolP("close-source", "c", false, "close source branch")
-	EditCmd.Flags().StringArrayP("reviewer", "r", []string{}, "add reviewer by their name. \033[31mNot implemented\033[m")
+	EditCmd.Flags().BoolP("close-source", "c", false, "close source branch after merging. Use --close-source=false to keep it")
+	EditCmd.Flags().StringArrayP("reviewer", "r", []string{}, `change reviewers by nickname, display name, account id or email. Multiple of these options can be given
+	prefix with "+" to add or "-" to remove a reviewer (--reviewer=-name), plain names replace the current reviewers`)
+	EditCmd.RegisterFlagCompletionFunc("reviewer", reviewerCompletion)
+	EditCmd.Flags().StringArray("add-reviewer", []string{}, "add a reviewer. Multiple of these options can be given")
+	EditCmd.RegisterFlagCompletionFunc("add-reviewer", reviewerCompletion)
+	EditCmd.Flags().StringArray("remove-reviewer", []string{}, "remove a reviewer. Multiple of these options can be given")
+	EditCmd.RegisterFlagCompletionFunc("remove-reviewer", reviewerCompletion)
+	EditCmd.Flags().BoolP("yes", "y", false, "update the pull request without asking for confirmation")
+}
+
+/* Prints the fields that change between the pull request and the update as a diff. Returns false when nothing changes */
+func printPrChanges(pr api.PullRequest, update api.CreatePullRequestBody, reviewers []api.User) bool {
+	var out strings.Builder
+	field := func(name string, old []string, new []string) {
+		removed, added := lineChanges(old, new)
+		if len(removed) == 0 && len(added) == 0 {
+			return
+		}
+		out.WriteString(fmt.Sprintf("  \033[1;37m%s\033[m\n", name))
+		for _, line := range removed {
+			out.WriteString(fmt.Sprintf("  \033[31m- %s\033[m\n", line))
+		}
+		for _, line := range added {
+			out.WriteString(fmt.Sprintf("  \033[32m+ %s\033[m\n", line))
+		}
+	}
+
+	field("title", []string{pr.Title}, []string{update.Title})
+	field("description", strings.Split(pr.Description, "\n"), strings.Split(update.Description, "\n"))
+	if update.Source != nil {
+		field("source", []string{pr.Source.Branch.Name}, []string{update.Source.Branch.Name})
+	}
+	if update.Destination != nil {
+		field("target", []string{pr.Destination.Branch.Name}, []string{update.Destination.Branch.Name})
+	}
+	field("close source branch", []string{fmt.Sprint(pr.CloseSource)}, []string{fmt.Sprint(update.CloseSource)})
+	oldReviewers, newReviewers := []string{}, []string{}
+	for _, user := range pr.Reviewers {
+		oldReviewers = append(oldReviewers, user.DisplayName)
+	}
+	for _, user := range reviewers {
+		newReviewers = append(newReviewers, user.DisplayName)
+	}
+	field("reviewers", oldReviewers, newReviewers)
+
+	if out.Len() == 0 {
+		return false
+	}
+	util.Printf("Changes to pull request \033[1;32m#%d\033[m:\n%s", pr.ID, out.String())
+	return true
+}
+
+/* Returns the lines only found on old and the ones only found on new */
+func lineChanges(old []string, new []string) ([]string, []string) {
+	count := map[string]int{}
+	for _, line := range old {
+		count[line]++
+	}
+	for _, line := range new {
+		count[line]--
+	}
+	removed, added := []string{}, []string{}
+	for _, line := range old {
+		if count[line] > 0 {
+			removed = append(removed, line)
+			count[line]--
+		}
+	}
+	for _, line := range new {
+		if count[line] < 0 {
+			added = append(added, line)
+			count[line]++
+		}
+	}
+	return removed, added
 }
 
 func readTitleAndDescription(pr api.PullRequest) (string, string) {
@@ -130,5 +223,6 @@ func readTitleAndDescription(pr api.PullRequest) (string, string) {
 	if len(lines) > 2 {
 		description = strings.Join(lines[2:], "\n")
 	}
-	return title, description
+	// editors add a newline at the end of the file
+	return title, strings.TrimRight(description, "\n")
 }
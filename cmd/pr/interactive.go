@@ -0,0 +1,431 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+)
+
+type reviewRowKind int
+
+const (
+	rowHeader reviewRowKind = iota
+	rowHunk
+	rowAdded
+	rowRemoved
+	rowContext
+	rowComment
+)
+
+type reviewRow struct {
+	kind      reviewRowKind
+	text      string
+	oldLine   int
+	newLine   int
+	commentId int
+}
+
+type interactiveReview struct {
+	repo     string
+	pr       api.PullRequest
+	files    []fileDiff
+	comments []api.PrComment
+	viewed   map[string]string // path of each viewed file with the hash of its diff
+	screen   tcell.Screen
+	file     int
+	cursor   int
+	offset   int
+	rows     []reviewRow
+	message  string
+	posted   int
+}
+
+var reviewStyles = map[reviewRowKind]tcell.Style{
+	rowHeader:  tcell.StyleDefault.Bold(true),
+	rowHunk:    tcell.StyleDefault.Foreground(tcell.ColorTeal),
+	rowAdded:   tcell.StyleDefault.Foreground(tcell.ColorGreen),
+	rowRemoved: tcell.StyleDefault.Foreground(tcell.ColorMaroon),
+	rowContext: tcell.StyleDefault,
+	rowComment: tcell.StyleDefault.Foreground(tcell.ColorOlive),
+}
+
+const reviewHelp = " j/k move  n/p file  v viewed  c comment  r reply  t task  q finish"
+
+/* Walks through the changes of a pull request in a full screen view and finishes with a review action */
+func runInteractiveReview(repo string, id int) {
+	diffChannel := api.GetPrDiff(repo, id)
+	commentsChannel := api.GetPrComments(repo, id)
+	r := &interactiveReview{repo: repo, pr: <-api.GetPr(repo, id)}
+	r.files = splitDiff(<-diffChannel)
+	r.comments = <-commentsChannel
+	if len(r.files) == 0 {
+		cobra.CheckErr(fmt.Sprintf("Pull request #%d has no changes", id))
+	}
+	r.viewed = readViewedFiles()[prKey(prRepo(r.pr, repo), id)]
+	if r.viewed == nil {
+		r.viewed = map[string]string{}
+	}
+	for r.file < len(r.files)-1 && r.isViewed(r.file) {
+		r.file++ // start on the first file not viewed yet
+	}
+
+	screen, err := tcell.NewScreen()
+	cobra.CheckErr(err)
+	cobra.CheckErr(screen.Init())
+	r.screen = screen
+	r.buildRows()
+	action := r.loop()
+	screen.Fini()
+	r.saveViewed()
+
+	if r.posted > 0 {
+		util.Printf("Posted \033[1;32m%d\033[m comments on pull request #%d\n", r.posted, id)
+	}
+	switch action {
+	case "approve":
+		cobra.CheckErr(api.ApprovePr(repo, id))
+		fmt.Printf("Pull request #%d \033[1;32mApproved\033[m\n", id)
+	case "request-changes":
+		cobra.CheckErr(api.RequestChangesPr(repo, id))
+		fmt.Printf("\033[1;34mRequested changes\033[m for pull request #%d\n", id)
+	}
+}
+
+/* Handles key presses until the review is finished, returning the chosen action */
+func (r *interactiveReview) loop() string {
+	finishing := false
+	for {
+		r.draw()
+		switch ev := r.screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			r.screen.Sync()
+		case *tcell.EventKey:
+			r.message = ""
+			if finishing {
+				finishing = false
+				switch ev.Rune() {
+				case 'a':
+					return "approve"
+				case 'r':
+					return "request-changes"
+				case 'c':
+					return "comment"
+				}
+				continue
+			}
+			_, height := r.screen.Size()
+			switch {
+			case ev.Key() == tcell.KeyDown || ev.Rune() == 'j':
+				r.moveCursor(1)
+			case ev.Key() == tcell.KeyUp || ev.Rune() == 'k':
+				r.moveCursor(-1)
+			case ev.Key() == tcell.KeyPgDn || ev.Key() == tcell.KeyCtrlD:
+				r.moveCursor(height / 2)
+			case ev.Key() == tcell.KeyPgUp || ev.Key() == tcell.KeyCtrlU:
+				r.moveCursor(-height / 2)
+			case ev.Key() == tcell.KeyHome || ev.Rune() == 'g':
+				r.moveCursor(-len(r.rows))
+			case ev.Key() == tcell.KeyEnd || ev.Rune() == 'G':
+				r.moveCursor(len(r.rows))
+			case ev.Rune() == 'n' || ev.Rune() == ']':
+				r.selectFile(r.file + 1)
+			case ev.Rune() == 'p' || ev.Rune() == '[':
+				r.selectFile(r.file - 1)
+			case ev.Rune() == 'v':
+				r.toggleViewed()
+			case ev.Rune() == 'c':
+				r.addComment(false)
+			case ev.Rune() == 't':
+				r.addComment(true)
+			case ev.Rune() == 'r':
+				r.reply()
+			case ev.Rune() == 'q' || ev.Key() == tcell.KeyEscape:
+				finishing = true
+				r.message = "Finish review: [a]pprove, [r]equest changes, [c]omment only or any other key to go back"
+			case ev.Key() == tcell.KeyCtrlC:
+				return ""
+			}
+		}
+	}
+}
+
+func (r *interactiveReview) draw() {
+	r.screen.Clear()
+	width, height := r.screen.Size()
+	bodyHeight := height - 3
+	if r.cursor < r.offset {
+		r.offset = r.cursor
+	} else if r.cursor >= r.offset+bodyHeight {
+		r.offset = r.cursor - bodyHeight + 1
+	}
+
+	viewed := ""
+	if r.isViewed(r.file) {
+		viewed = "  ✓ viewed"
+	}
+	header := fmt.Sprintf(" #%d %s  [%d/%d] %s%s", r.pr.ID, r.pr.Title, r.file+1, len(r.files), r.files[r.file].Path, viewed)
+	drawReviewLine(r.screen, 0, width, header, tcell.StyleDefault.Reverse(true))
+
+	for i := 0; i < bodyHeight && r.offset+i < len(r.rows); i++ {
+		row := r.rows[r.offset+i]
+		style := reviewStyles[row.kind]
+		if r.offset+i == r.cursor {
+			style = style.Background(tcell.ColorDarkSlateGray)
+		}
+		gutter := "     "
+		if row.kind == rowAdded || row.kind == rowContext {
+			gutter = fmt.Sprintf("%4d ", row.newLine)
+		} else if row.kind == rowRemoved {
+			gutter = fmt.Sprintf("%4d ", row.oldLine)
+		}
+		drawReviewLine(r.screen, 1+i, width, gutter+row.text, style)
+	}
+
+	drawReviewLine(r.screen, height-2, width, r.message, tcell.StyleDefault.Bold(true))
+	drawReviewLine(r.screen, height-1, width, reviewHelp, tcell.StyleDefault.Reverse(true))
+	r.screen.Show()
+}
+
+/* Draws text on a line of the screen, filling the rest of it with the style */
+func drawReviewLine(screen tcell.Screen, y int, width int, text string, style tcell.Style) {
+	x := 0
+	for _, char := range strings.ReplaceAll(text, "\t", "    ") {
+		charWidth := runewidth.RuneWidth(char)
+		if x+charWidth > width {
+			break
+		}
+		screen.SetContent(x, y, char, nil, style)
+		x += charWidth
+	}
+	for ; x < width; x++ {
+		screen.SetContent(x, y, ' ', nil, style)
+	}
+}
+
+/* Builds the rows of the current file with the comment threads after the line they refer to */
+func (r *interactiveReview) buildRows() {
+	file := r.files[r.file]
+	roots, replies := groupCommentThreads(r.comments)
+	fileRoots := []api.PrComment{}
+	for _, root := range roots {
+		if root.Inline != nil && (root.Inline.Path == file.Path || root.Inline.Path == file.OldPath) {
+			fileRoots = append(fileRoots, root)
+		}
+	}
+
+	header := file.Path
+	if file.OldPath != file.Path {
+		header = file.OldPath + " → " + file.Path
+	}
+	r.rows = []reviewRow{{kind: rowHeader, text: header}}
+	placed := map[int]bool{}
+	diffRows := []reviewRow{}
+	oldLine, newLine := 0, 0
+	inHunk := false
+	for _, text := range strings.Split(file.Content, "\n") {
+		if match := hunkHeaderRegex.FindStringSubmatch(text); match != nil {
+			oldLine, _ = strconv.Atoi(match[1])
+			newLine, _ = strconv.Atoi(match[2])
+			inHunk = true
+			diffRows = append(diffRows, reviewRow{kind: rowHunk, text: text})
+			continue
+		}
+		if !inHunk || text == "" || strings.HasPrefix(text, "\\") {
+			continue // file header or "no newline at end of file"
+		}
+		row := reviewRow{text: text, oldLine: oldLine, newLine: newLine}
+		switch text[0] {
+		case '+':
+			row.kind, row.oldLine = rowAdded, 0
+			newLine++
+		case '-':
+			row.kind, row.newLine = rowRemoved, 0
+			oldLine++
+		default:
+			row.kind = rowContext
+			oldLine++
+			newLine++
+		}
+		diffRows = append(diffRows, row)
+		for _, root := range fileRoots {
+			line, oldSide := commentLine(root)
+			if !placed[root.Id] && !root.Inline.Outdated && line != 0 &&
+				((oldSide && line == row.oldLine) || (!oldSide && line == row.newLine)) {
+				placed[root.Id] = true
+				diffRows = append(diffRows, commentRows(root, replies, 0)...)
+			}
+		}
+	}
+	// comments on the whole file or on lines that are no longer in the diff
+	for _, root := range fileRoots {
+		if !placed[root.Id] {
+			r.rows = append(r.rows, commentRows(root, replies, 0)...)
+		}
+	}
+	r.rows = append(r.rows, diffRows...)
+	if r.cursor >= len(r.rows) {
+		r.cursor = len(r.rows) - 1
+	}
+}
+
+func commentRows(comment api.PrComment, replies map[int][]api.PrComment, depth int) []reviewRow {
+	indent := "    ┃ " + strings.Repeat("  ", depth)
+	title := fmt.Sprintf("%s%s #%d", indent, comment.User.DisplayName, comment.Id)
+	if comment.Inline != nil && comment.Inline.Outdated {
+		title += " (outdated)"
+	}
+	if comment.Resolution != nil {
+		title += " ✓ resolved"
+	}
+	rows := []reviewRow{{kind: rowComment, text: title, commentId: comment.Id}}
+	content := comment.Content.Raw
+	if comment.Deleted {
+		content = "[deleted]"
+	}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		rows = append(rows, reviewRow{kind: rowComment, text: indent + "  " + line, commentId: comment.Id})
+	}
+	for _, reply := range replies[comment.Id] {
+		rows = append(rows, commentRows(reply, replies, depth+1)...)
+	}
+	return rows
+}
+
+func (r *interactiveReview) moveCursor(delta int) {
+	r.cursor += delta
+	if r.cursor >= len(r.rows) {
+		r.cursor = len(r.rows) - 1
+	}
+	if r.cursor < 0 {
+		r.cursor = 0
+	}
+}
+
+func (r *interactiveReview) selectFile(index int) {
+	if index < 0 || index >= len(r.files) {
+		return
+	}
+	r.file, r.cursor, r.offset = index, 0, 0
+	r.buildRows()
+}
+
+func (r *interactiveReview) isViewed(index int) bool {
+	file := r.files[index]
+	return r.viewed[file.Path] == fmt.Sprintf("%x", sha1.Sum([]byte(file.Content)))
+}
+
+/* Marks the current file as viewed and moves to the next one not viewed, or unmarks it */
+func (r *interactiveReview) toggleViewed() {
+	file := r.files[r.file]
+	if r.isViewed(r.file) {
+		delete(r.viewed, file.Path)
+		return
+	}
+	r.viewed[file.Path] = fmt.Sprintf("%x", sha1.Sum([]byte(file.Content)))
+	for next := r.file + 1; next < len(r.files); next++ {
+		if !r.isViewed(next) {
+			r.selectFile(next)
+			return
+		}
+	}
+	r.message = "All files viewed, press q to finish"
+}
+
+/* Writes a comment on the line under the cursor in the EDITOR, optionally also creating a task for it */
+func (r *interactiveReview) addComment(task bool) {
+	row := r.rows[r.cursor]
+	if task && row.kind == rowComment {
+		r.withSuspendedScreen(func() {
+			message := util.ReadDescription("")
+			if message == "" {
+				return
+			}
+			r.postTask(message, row.commentId)
+		})
+		return
+	}
+	if row.kind != rowAdded && row.kind != rowRemoved && row.kind != rowContext {
+		r.message = "Move the cursor to a line of the diff to comment on it"
+		return
+	}
+
+	body := api.CreateCommentBody{}
+	body.Inline = &api.CommentInline{Path: r.files[r.file].Path}
+	if row.kind == rowRemoved {
+		body.Inline.From = &row.oldLine
+	} else {
+		body.Inline.To = &row.newLine
+	}
+	r.withSuspendedScreen(func() {
+		body.Content.Raw = util.ReadDescription("")
+		if body.Content.Raw == "" {
+			return
+		}
+		comment := api.PostPrComment(r.repo, r.pr.ID, body)
+		r.comments = append(r.comments, comment)
+		r.posted++
+		r.message = fmt.Sprintf("Comment #%d added", comment.Id)
+		if task {
+			r.postTask(body.Content.Raw, comment.Id)
+		}
+	})
+}
+
+func (r *interactiveReview) postTask(message string, commentId int) {
+	taskBody := api.CreateTaskBody{Comment: &api.CommentParent{Id: commentId}}
+	taskBody.Content.Raw = message
+	created := api.PostPrTask(r.repo, r.pr.ID, taskBody)
+	r.message = fmt.Sprintf("Task #%d added on comment #%d", created.Id, commentId)
+}
+
+func (r *interactiveReview) reply() {
+	row := r.rows[r.cursor]
+	if row.kind != rowComment {
+		r.message = "Move the cursor to a comment to reply to it"
+		return
+	}
+	r.withSuspendedScreen(func() {
+		body := api.CreateCommentBody{Parent: &api.CommentParent{Id: row.commentId}}
+		body.Content.Raw = util.ReadDescription("")
+		if body.Content.Raw == "" {
+			return
+		}
+		comment := api.PostPrComment(r.repo, r.pr.ID, body)
+		// replies don't always include the inline information of their thread
+		for _, parent := range r.comments {
+			if parent.Id == row.commentId && comment.Inline == nil {
+				comment.Inline = parent.Inline
+			}
+		}
+		r.comments = append(r.comments, comment)
+		r.posted++
+		r.message = fmt.Sprintf("Reply #%d added", comment.Id)
+	})
+}
+
+/* Runs fn with the terminal restored so the EDITOR can be used and errors are printed normally */
+func (r *interactiveReview) withSuspendedScreen(fn func()) {
+	cobra.CheckErr(r.screen.Suspend())
+	fn()
+	cobra.CheckErr(r.screen.Resume())
+	r.buildRows()
+}
+
+func readViewedFiles() map[string]map[string]string {
+	viewed := map[string]map[string]string{}
+	util.ReadCache("viewed-files.json", &viewed)
+	return viewed
+}
+
+func (r *interactiveReview) saveViewed() {
+	viewed := readViewedFiles()
+	viewed[prKey(prRepo(r.pr, r.repo), r.pr.ID)] = r.viewed
+	util.WriteCache("viewed-files.json", viewed)
+}
@@ -4,53 +4,107 @@ import (
 	"bb/api"
 	"bb/util"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var ListCmd = &cobra.Command{
-	Use:     "list",
-	Short:   "List pull requests from a repository",
+	Use:   "list",
+	Short: "List pull requests from a repository",
+	Long: `List pull requests from a repository.
+	With --workspace or --repos the repositories of a workspace are queried concurrently and the results are sorted by update time`,
 	Aliases: []string{"ls"},
 	Args:    cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		author, _ := cmd.Flags().GetString("author")
-		search, _ := cmd.Flags().GetString("search")
-		pages, _ := cmd.Flags().GetInt("pages")
-		states, _ := cmd.Flags().GetStringArray("state")
+		repo := viper.GetString("repo")
+		options := api.PrListOptions{}
+		options.Author, _ = cmd.Flags().GetString("author")
+		options.Search, _ = cmd.Flags().GetString("search")
+		options.Pages, _ = cmd.Flags().GetInt("pages")
+		options.States, _ = cmd.Flags().GetStringArray("state")
 		allStates, _ := cmd.Flags().GetBool("all")
 		if allStates {
-			states = []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}
+			options.States = []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}
 		}
-		source, _ := cmd.Flags().GetString("source")
-		target, _ := cmd.Flags().GetString("target")
+		options.Source, _ = cmd.Flags().GetString("source")
+		options.Destination, _ = cmd.Flags().GetString("target")
 		status, _ := cmd.Flags().GetBool("status")
 		participants, _ := cmd.Flags().GetBool("participants")
+		options.Status, options.Participants = status, participants
+		if cmd.Flags().Changed("draft") {
+			onlyDrafts, _ := cmd.Flags().GetBool("draft")
+			options.Draft = &onlyDrafts
+		} else if noDraft, _ := cmd.Flags().GetBool("no-draft"); noDraft {
+			options.Draft = new(bool)
+		}
 
-		count := 0
-		for pr := range api.GetPrList(viper.GetString("repo"), states, author, search, source, target, pages, status, participants) {
-			util.Printf("%s \033[1;32m#%d\033[m %s \033[1;34m[ %s \033[m→\033[1;34m %s ]\033[m \033[33m%s\033[m", util.FormatPrState(pr.State), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name, pr.Author.Nickname)
-			if status {
-				util.Printf(" %s", util.FormatPipelineStatus(pr.Status.State))
+		workspace, _ := cmd.Flags().GetString("workspace")
+		repoPatterns, _ := cmd.Flags().GetStringSlice("repos")
+		if workspace == "" && len(repoPatterns) > 0 {
+			workspace = strings.Split(repo, "/")[0]
+		}
+
+		var candidates []api.User
+		resolveUser := func(flag string) string {
+			name, _ := cmd.Flags().GetString(flag)
+			if name == "" {
+				return ""
+			} else if name == "@me" || name == "me" {
+				return api.GetUser().UUID
 			}
-			if participants {
-				var outputStr = []string{}
-				for _, participant := range pr.Participants {
-					if participant.Approved {
-						outputStr = append(outputStr, fmt.Sprintf("\033[1;32m✓ %s\033[m", participant.User.DisplayName))
-					} else {
-						outputStr = append(outputStr, fmt.Sprintf("\033[0;37m%s\033[m", participant.User.DisplayName))
-					}
-				}
-				util.Printf("\n       \033[37mComments: %d\033[m ( %s )", pr.CommentCount, strings.Join(outputStr, ", "))
+			if candidates == nil && workspace != "" {
+				candidates = <-api.GetWorkspaceMembers(workspace)
+			} else if candidates == nil {
+				candidates = util.LoadReviewerCandidates(repo, "")
+			}
+			user, err := util.ResolveReviewer(name, candidates, workspaceOf(repo, workspace))
+			cobra.CheckErr(err)
+			return user.UUID
+		}
+		options.Reviewer = resolveUser("reviewer")
+		options.Participant = resolveUser("participant")
+		options.ApprovedBy = resolveUser("approved-by")
+		if needsMyReview, _ := cmd.Flags().GetBool("needs-my-review"); needsMyReview {
+			options.Reviewer = api.GetUser().UUID
+			options.NotApprovedBy = options.Reviewer
+		}
+
+		var err error
+		updatedSince, _ := cmd.Flags().GetString("updated-since")
+		options.UpdatedSince, err = parseDateFlag(updatedSince)
+		cobra.CheckErr(err)
+		createdBefore, _ := cmd.Flags().GetString("created-before")
+		options.CreatedBefore, err = parseDateFlag(createdBefore)
+		cobra.CheckErr(err)
+
+		sortField, _ := cmd.Flags().GetString("sort")
+		options.Sort, err = prSortField(sortField)
+		cobra.CheckErr(err)
+		options.Query, _ = cmd.Flags().GetString("query")
+
+		if workspace != "" {
+			prs := listWorkspacePrs(workspace, repoPatterns, options)
+			for _, pr := range prs {
+				printPrListItem(pr, prRepo(pr, ""), status, participants)
 			}
-			fmt.Println()
+			if len(prs) == 0 {
+				util.Printf("No pull requests for \033[1;36m%s\033[m\n", workspace)
+			}
+			return
+		}
+
+		count := 0
+		for pr := range api.GetPrList(repo, options) {
+			printPrListItem(pr, "", status, participants)
 			count++
 		}
 		if count == 0 {
-			util.Printf("No pull requests for \033[1;36m%s\033[m\n", viper.GetString("repo"))
+			util.Printf("No pull requests for \033[1;36m%s\033[m\n", repo)
 		}
 	},
 }
@@ -66,6 +120,28 @@ func init() {
 	ListCmd.Flags().String("source", "", "filter by source branch.")
 	ListCmd.RegisterFlagCompletionFunc("source", util.BranchCompletion)
 	ListCmd.Flags().Bool("all", false, "return pull request with all possible states.")
+	ListCmd.Flags().Bool("draft", false, "show only draft pull requests.")
+	ListCmd.Flags().Bool("no-draft", false, "hide draft pull requests.")
+	ListCmd.MarkFlagsMutuallyExclusive("draft", "no-draft")
+
+	ListCmd.Flags().String("reviewer", "", `filter by reviewer (nickname, name, email or "@me")`)
+	ListCmd.RegisterFlagCompletionFunc("reviewer", reviewerCompletion)
+	ListCmd.Flags().String("participant", "", `filter by participant (nickname, name, email or "@me")`)
+	ListCmd.RegisterFlagCompletionFunc("participant", reviewerCompletion)
+	ListCmd.Flags().String("approved-by", "", `show only pull requests approved by this user (nickname, name, email or "@me")`)
+	ListCmd.RegisterFlagCompletionFunc("approved-by", reviewerCompletion)
+	ListCmd.Flags().Bool("needs-my-review", false, "show only pull requests where you are a reviewer and haven't approved yet")
+	ListCmd.Flags().String("updated-since", "", `show only pull requests updated since a date (ex: "2024-01-31") or for a duration (ex: "3d", "2w", "12h")`)
+	ListCmd.Flags().String("created-before", "", `show only pull requests created before a date (ex: "2024-01-31") or a duration ago (ex: "3d", "2w", "12h")`)
+	ListCmd.Flags().String("sort", "", `sort by "id", "created", "updated" or "title". Prefix with "-" for descending order. Default: "-id"`)
+	ListCmd.RegisterFlagCompletionFunc("sort", func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"id", "-id", "created", "-created", "updated", "-updated", "title", "-title"}, cobra.ShellCompDirectiveDefault
+	})
+	ListCmd.Flags().StringP("query", "q", "", `raw bitbucket query added to the filters (ex: 'comment_count > 5')`)
+
+	ListCmd.Flags().StringP("workspace", "w", "", "list pull requests from all repositories of a workspace")
+	ListCmd.Flags().StringSlice("repos", []string{}, `list pull requests only from repositories matching these patterns (ex: "api-*,web")
+	uses the workspace of the current repository if --workspace is not given`)
 
 	ListCmd.Flags().Int("pages", 1, "number of pages with results to retrieve")
 	ListCmd.Flags().BoolP("status", "S", false, "include status of each pull request on the result. (the result will be slower)")
@@ -75,3 +151,125 @@ func init() {
 func stateCompletion(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return []string{"open", "merged", "declined", "superseded"}, cobra.ShellCompDirectiveDefault
 }
+
+/* Parses a date, a date and time or a duration into the past such as "3d" */
+func parseDateFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04"} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	if unit, ok := units[value[len(value)-1:]]; ok {
+		if n, err := strconv.Atoi(value[:len(value)-1]); err == nil {
+			return time.Now().Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid date or duration '%s'", value)
+}
+
+func prSortField(sort string) (string, error) {
+	order := ""
+	if strings.HasPrefix(sort, "-") {
+		order, sort = "-", sort[1:]
+	}
+	switch sort {
+	case "":
+		return "", nil
+	case "id", "title", "created_on", "updated_on":
+	case "created", "updated":
+		sort += "_on"
+	default:
+		return "", fmt.Errorf("Invalid sort field '%s'", sort)
+	}
+	return order + sort, nil
+}
+
+func printPrListItem(pr api.PullRequest, repoColumn string, status bool, participants bool) {
+	if repoColumn != "" {
+		util.Printf("\033[1;36m%-30s\033[m ", repoColumn)
+	}
+	util.Printf("%s %s\033[1;32m#%d\033[m %s \033[1;34m[ %s \033[m→\033[1;34m %s ]\033[m \033[33m%s\033[m", util.FormatPrState(pr.State), util.FormatPrDraft(pr.Draft), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name, pr.Author.Nickname)
+	if status {
+		util.Printf(" %s", util.FormatPipelineStatus(pr.Status.State))
+	}
+	if participants {
+		var outputStr = []string{}
+		for _, participant := range pr.Participants {
+			if participant.Approved {
+				outputStr = append(outputStr, fmt.Sprintf("\033[1;32m✓ %s\033[m", participant.User.DisplayName))
+			} else {
+				outputStr = append(outputStr, fmt.Sprintf("\033[0;37m%s\033[m", participant.User.DisplayName))
+			}
+		}
+		util.Printf("\n       \033[37mComments: %d\033[m ( %s )", pr.CommentCount, strings.Join(outputStr, ", "))
+	}
+	fmt.Println()
+}
+
+/* Lists pull requests from the repositories of a workspace, using the endpoint for the author when filtering by one */
+func listWorkspacePrs(workspace string, repoPatterns []string, options api.PrListOptions) []api.PullRequest {
+	var prs []api.PullRequest
+	author := api.User{}
+	if options.Author != "" && len(repoPatterns) == 0 {
+		for _, member := range <-api.GetWorkspaceMembers(workspace) {
+			if strings.EqualFold(member.Nickname, options.Author) {
+				author = member
+			}
+		}
+	}
+	if author.UUID != "" {
+		prs = <-api.GetWorkspacePrsByUser(workspace, author.UUID, options)
+		if options.Status {
+			for i := range prs {
+				if statuses := <-api.GetPrStatuses(prRepo(prs[i], ""), prs[i].ID); len(statuses) > 0 {
+					prs[i].Status = statuses[0]
+				}
+			}
+		}
+	} else {
+		prs = collectWorkspacePrs(workspace, repoPatterns, func(repository string) []api.PullRequest {
+			repoPrs := []api.PullRequest{}
+			for pr := range api.GetPrList(repository, options) {
+				if pr.Destination.Repository.FullName == "" {
+					pr.Destination.Repository.FullName = repository
+				}
+				repoPrs = append(repoPrs, pr)
+			}
+			return repoPrs
+		})
+	}
+
+	field := strings.TrimPrefix(options.Sort, "-")
+	descending := options.Sort == "" || strings.HasPrefix(options.Sort, "-")
+	sort.SliceStable(prs, func(i, j int) bool {
+		a, b := prs[i], prs[j]
+		if descending {
+			a, b = b, a
+		}
+		switch field {
+		case "id":
+			return a.ID < b.ID
+		case "title":
+			return a.Title < b.Title
+		case "created_on":
+			return a.CreatedOn.Before(b.CreatedOn)
+		default:
+			return a.UpdatedOn.Before(b.UpdatedOn)
+		}
+	})
+	return prs
+}
+
+func workspaceOf(repo string, workspace string) string {
+	if workspace != "" {
+		return workspace
+	}
+	return strings.Split(repo, "/")[0]
+}
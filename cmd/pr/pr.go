@@ -1,7 +1,11 @@
 package pr
 
 import (
+	"bb/api"
 	"bb/util"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,7 +21,8 @@ var PrCmd = &cobra.Command{
 		if curRepo := util.GetCurrentRepo(); curRepo != "" {
 			viper.SetDefault("repo", curRepo)
 		}
-		if !viper.IsSet("repo") {
+		// commands working on a whole workspace don't need a repository
+		if workspace := cmd.Flags().Lookup("workspace"); !viper.IsSet("repo") && (workspace == nil || !workspace.Changed) {
 			cobra.CheckErr("repo is not defined")
 		}
 	},
@@ -29,5 +34,38 @@ func init() {
 	PrCmd.AddCommand(EditCmd)
 	PrCmd.AddCommand(ViewCmd)
 	PrCmd.AddCommand(ReviewCmd)
+	PrCmd.AddCommand(ReadyCmd)
+	PrCmd.AddCommand(CheckoutCmd)
+	PrCmd.AddCommand(DiffCmd)
+	PrCmd.AddCommand(CommentCmd)
+	PrCmd.AddCommand(TaskCmd)
+	PrCmd.AddCommand(StatusCmd)
+	PrCmd.AddCommand(CommitsCmd)
+	PrCmd.AddCommand(ActivityCmd)
+	PrCmd.AddCommand(CheckCmd)
 	PrCmd.PersistentFlags().StringP("repo", "R", "", "selected repository")
 }
+
+/* Returns the pull request ID given as argument or the one of the first pull request found for the current branch */
+func getPrId(repo string, args []string) int {
+	if len(args) > 0 {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		cobra.CheckErr(err)
+		return id
+	}
+	branch, err := util.GetCurrentBranch()
+	cobra.CheckErr(err)
+	pr := <-api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, Source: branch})
+	if pr.ID == 0 {
+		cobra.CheckErr("No pr found for this branch")
+	}
+	return pr.ID
+}
+
+func openPrCompletion(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var opt = []string{}
+	for pr := range api.GetPrList(util.GetCurrentRepo(), api.PrListOptions{States: []string{string(api.OPEN)}}) {
+		opt = append(opt, fmt.Sprintf("%d\t%s", pr.ID, pr.Title))
+	}
+	return opt, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,46 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ReadyCmd = &cobra.Command{
+	Use:   "ready [ID]",
+	Short: "Mark a draft pull request as ready for review",
+	Long: `Publish a draft pull request so that reviewers are notified.
+	If no ID is given the pull request of the current branch is used`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+
+		existingPr := <-api.GetPr(repo, id)
+		undo, _ := cmd.Flags().GetBool("undo")
+		if existingPr.Draft != undo {
+			if undo {
+				util.Printf("Pull request #%d is already a draft\n", id)
+			} else {
+				util.Printf("Pull request #%d is already ready for review\n", id)
+			}
+			return
+		}
+
+		body := api.PrUpdateBody(existingPr)
+		body.Draft = &undo
+		pr := api.UpdatePr(repo, id, body)
+		if pr.Draft {
+			util.Printf("Pull request #%d converted to \033[1;37mdraft\033[m\n", pr.ID)
+		} else {
+			util.Printf("Pull request #%d is \033[1;32mready for review\033[m\n", pr.ID)
+		}
+	},
+}
+
+func init() {
+	ReadyCmd.Flags().Bool("undo", false, "convert the pull request back to a draft")
+}
@@ -4,79 +4,125 @@ import (
 	"bb/api"
 	"bb/util"
 	"fmt"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var ReviewCmd = &cobra.Command{
-	Use:   "review [ID]...",
+	Use:   "review [ID | BRANCH]...",
 	Short: "Review a pull request (merge, approve, unnaprove, decline ...)",
 	Long: `Merge, approve, unnaprove, decline or request/unrequest changes in a pull request
-	If no ID is given the operation will be applied to the first PR found for the current branch`,
-	ValidArgsFunction: func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		var opt = []string{}
-		for pr := range api.GetPrList(util.GetCurrentRepo(), []string{string(api.OPEN)}, "", "", "", "", 1, false, false) {
-			opt = append(opt, fmt.Sprint(pr.ID))
-		}
-		return opt, cobra.ShellCompDirectiveDefault
-	},
+	Multiple pull requests can be given by ID or by the name of their source branch.
+	If none is given the operation will be applied to the first PR found for the current branch`,
+	ValidArgsFunction: openPrCompletion,
 	Run: func(cmd *cobra.Command, args []string) {
 		repo := viper.GetString("repo")
 
-		// TODO allow to give source branch name instead of just using current branch
-		var id int
-		var err error
-		if len(args) == 0 {
-			branch, err := util.GetCurrentBranch()
-			cobra.CheckErr(err)
-			// retrieve id of pr for current branch
-			pr := <-api.GetPrList(repo, []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, "", "", branch, "", 1, false, false)
-			if pr.ID == 0 {
-				cobra.CheckErr("No pr found for this branch")
-			}
-			id = pr.ID // get the first one's ID
-		} else {
-			// TODO allow multiple pr ids to be given
-			id, err = strconv.Atoi(args[0])
-			cobra.CheckErr(err)
-		}
-
 		approve, _ := cmd.Flags().GetBool("approve")
-		if approve {
-			api.ApprovePr(repo, id)
-			fmt.Printf("Pull request #%d \033[1;32mApproved\033[m\n", id)
-		}
 		unnaprove, _ := cmd.Flags().GetBool("unnaprove")
-		if unnaprove {
-			api.UnnaprovePr(repo, id)
-			fmt.Printf("Pull request #%d \033[1;33mUnnaproved\033[m\n", id)
-		}
 		decline, _ := cmd.Flags().GetBool("decline")
-		if decline {
-			api.DeclinePr(repo, id)
-			fmt.Printf("Pull request #%d \033[1;31mDeclined\033[m\n", id)
-		}
 		merge, _ := cmd.Flags().GetBool("merge")
-		if merge {
-			message,_ := cmd.Flags().GetString("message")
-			api.MergePr(repo, id, message)
-			fmt.Printf("\033[1;35mMerge\033[m pull request #%d\n", id)
-		}
 		requestChanges, _ := cmd.Flags().GetBool("request-changes")
-		if requestChanges {
-			api.RequestChangesPr(repo, id)
-			fmt.Printf("\033[1;34mRequested changes\033[m for pull request #%d\n", id)
-		}
 		unrequestChanges, _ := cmd.Flags().GetBool("unrequest-changes")
-		if unrequestChanges {
-			fmt.Printf("Not implemented")
+
+		if interactive, _ := cmd.Flags().GetBool("interactive"); interactive {
+			if len(args) > 1 {
+				cobra.CheckErr("Only one pull request can be reviewed interactively")
+			}
+			runInteractiveReview(repo, getPrId(repo, args))
+			return
 		}
 
 		if !merge && !approve && !unnaprove && !decline && !requestChanges && !unrequestChanges {
 			fmt.Println("No operation selected")
 			cmd.Help()
+			return
+		}
+
+		strategy, _ := cmd.Flags().GetString("strategy")
+		switch api.MergeStrategy(strategy) {
+		case "", api.MERGE_COMMIT, api.SQUASH, api.FAST_FORWARD:
+		default:
+			cobra.CheckErr(fmt.Sprintf(`Strategy must be one of "%s", "%s" or "%s"`, api.MERGE_COMMIT, api.SQUASH, api.FAST_FORWARD))
+		}
+
+		whenGreen, _ := cmd.Flags().GetBool("when-green")
+		background, _ := cmd.Flags().GetBool("background")
+		if whenGreen && !merge {
+			cobra.CheckErr("--when-green can only be used with --merge")
+		}
+		if background && !whenGreen {
+			cobra.CheckErr("--background can only be used with --when-green")
+		}
+
+		ids := []int{}
+		if len(args) == 0 {
+			ids = append(ids, getPrId(repo, args))
+		}
+		for _, arg := range args {
+			ids = append(ids, prIdFromIdOrBranch(repo, arg))
+		}
+
+		if background {
+			runInBackground(ids)
+			return
+		}
+
+		results := []reviewResult{}
+		for _, id := range ids {
+			var err error
+			action := ""
+			switch {
+			case approve:
+				action = "approve"
+				if err = api.ApprovePr(repo, id); err == nil {
+					fmt.Printf("Pull request #%d \033[1;32mApproved\033[m\n", id)
+				}
+			case unnaprove:
+				action = "unnaprove"
+				if err = api.UnnaprovePr(repo, id); err == nil {
+					fmt.Printf("Pull request #%d \033[1;33mUnnaproved\033[m\n", id)
+				}
+			case decline:
+				action = "decline"
+				if err = api.DeclinePr(repo, id); err == nil {
+					fmt.Printf("Pull request #%d \033[1;31mDeclined\033[m\n", id)
+				}
+			case merge:
+				action = "merge"
+				if err = mergePr(cmd, repo, id); err == nil {
+					fmt.Printf("\033[1;35mMerge\033[m pull request #%d\n", id)
+				}
+			case requestChanges:
+				action = "request changes"
+				if err = api.RequestChangesPr(repo, id); err == nil {
+					fmt.Printf("\033[1;34mRequested changes\033[m for pull request #%d\n", id)
+				}
+			case unrequestChanges:
+				action = "unrequest changes"
+				if err = api.UnrequestChangesPr(repo, id); err == nil {
+					fmt.Printf("\033[1;34mRemoved change request\033[m for pull request #%d\n", id)
+				}
+			}
+			if err != nil && len(ids) == 1 {
+				cobra.CheckErr(err)
+			}
+			results = append(results, reviewResult{id, action, err})
+		}
+
+		if len(results) > 1 {
+			printReviewSummary(results)
+			for _, result := range results {
+				if result.err != nil {
+					os.Exit(1)
+				}
+			}
 		}
 	},
 }
@@ -87,8 +133,90 @@ func init() {
 	ReviewCmd.Flags().BoolP("unnaprove", "u", false, "Unnaprove pull request")
 	ReviewCmd.Flags().BoolP("decline", "d", false, "Decline pull request")
 	ReviewCmd.Flags().BoolP("request-changes", "c", false, "Request changes to the pull request")
-	ReviewCmd.Flags().BoolP("unrequest-changes", "U", false, "Remove request changes status from pull request. \033[31mNot implemented\033[m")
-	ReviewCmd.MarkFlagsMutuallyExclusive("merge", "approve", "unnaprove", "decline", "request-changes", "unrequest-changes")
+	ReviewCmd.Flags().BoolP("unrequest-changes", "U", false, "Remove request changes status from pull request")
+	ReviewCmd.Flags().BoolP("interactive", "i", false, `Walk through the changes in a full screen view to comment on them and finish with a review
+	use j/k to move, n/p to change file, v to mark a file as viewed, c to comment, r to reply, t to add a task and q to finish`)
+	ReviewCmd.MarkFlagsMutuallyExclusive("merge", "approve", "unnaprove", "decline", "request-changes", "unrequest-changes", "interactive")
+
+	ReviewCmd.Flags().String("message", "", `Attach message to action (merge)
+	Defaults to the pull request title prefixed by the Jira issue key of the source branch`)
+	ReviewCmd.Flags().String("strategy", "", `Merge strategy: "merge_commit", "squash" or "fast_forward". Defaults to the repository setting`)
+	ReviewCmd.RegisterFlagCompletionFunc("strategy", func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{string(api.MERGE_COMMIT), string(api.SQUASH), string(api.FAST_FORWARD)}, cobra.ShellCompDirectiveDefault
+	})
+	ReviewCmd.Flags().Bool("close-source", false, "Close the source branch after merging. Defaults to the pull request setting")
+	ReviewCmd.Flags().Bool("ignore-tasks", false, "Merge even if the pull request has unresolved tasks")
+	ReviewCmd.Flags().Bool("when-green", false, `Wait for all statuses to pass, with at least one or the builds required by the branch restrictions, and the required approvals before merging.
+	Aborts if a status fails or the pull request is declined or updated meanwhile`)
+	ReviewCmd.Flags().Duration("timeout", time.Hour, "Maximum time to wait with --when-green (0 waits forever)")
+	ReviewCmd.Flags().Int("approvals", 0, "Approvals required with --when-green. Defaults to the branch restrictions of the target branch")
+	ReviewCmd.Flags().Bool("background", false, "Wait with --when-green in the background, logging to a temporary file")
+}
+
+type reviewResult struct {
+	id     int
+	action string
+	err    error
+}
 
-	ReviewCmd.Flags().String("message", "", "Attach message to action (merge)")
+/* Returns the ID given or the ID of the open pull request with branch as source */
+func prIdFromIdOrBranch(repo string, arg string) int {
+	if id, err := strconv.Atoi(strings.TrimPrefix(arg, "#")); err == nil {
+		return id
+	}
+	pr := <-api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN)}, Source: arg})
+	if pr.ID == 0 {
+		cobra.CheckErr(fmt.Sprintf("No open pull request found for branch '%s'", arg))
+	}
+	return pr.ID
+}
+
+func mergePr(cmd *cobra.Command, repo string, id int) error {
+	pr := <-api.GetPr(repo, id)
+	ignoreTasks, _ := cmd.Flags().GetBool("ignore-tasks")
+	if unresolved := countUnresolvedTasks(<-api.GetPrTasks(repo, id)); unresolved > 0 {
+		util.Printf("\033[1;33mWarning:\033[m pull request #%d has %d unresolved tasks\n", id, unresolved)
+		if !ignoreTasks {
+			return fmt.Errorf("Resolve the tasks or use --ignore-tasks to merge anyway")
+		}
+	}
+	if whenGreen, _ := cmd.Flags().GetBool("when-green"); whenGreen {
+		if err := waitForGreen(cmd, repo, pr); err != nil {
+			return err
+		}
+	}
+
+	body := api.MergePullRequestBody{}
+	strategy, _ := cmd.Flags().GetString("strategy")
+	body.Strategy = api.MergeStrategy(strategy)
+	if cmd.Flags().Changed("close-source") {
+		closeSource, _ := cmd.Flags().GetBool("close-source")
+		body.CloseSource = &closeSource
+	}
+	body.Message, _ = cmd.Flags().GetString("message")
+	if body.Message == "" && body.Strategy != api.FAST_FORWARD {
+		body.Message = defaultMergeMessage(pr)
+	}
+	return api.MergePr(repo, id, body)
+}
+
+func defaultMergeMessage(pr api.PullRequest) string {
+	re := regexp.MustCompile(api.JiraIssueKeyRegex)
+	title := pr.Title
+	if key := re.FindString(pr.Source.Branch.Name); key != "" && !strings.Contains(title, key) {
+		title = key + " " + title
+	}
+	return fmt.Sprintf("%s (pull request #%d)", title, pr.ID)
+}
+
+func printReviewSummary(results []reviewResult) {
+	fmt.Println()
+	util.Printf("\033[1;37m%-8s %-18s %s\033[m\n", "PR", "ACTION", "RESULT")
+	for _, result := range results {
+		if result.err == nil {
+			util.Printf("%-8s %-18s \033[1;32m✓ done\033[m\n", fmt.Sprintf("#%d", result.id), result.action)
+		} else {
+			util.Printf("%-8s %-18s \033[1;31m✗ %s\033[m\n", fmt.Sprintf("#%d", result.id), result.action, result.err)
+		}
+	}
 }
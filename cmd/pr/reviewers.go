@@ -0,0 +1,61 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+/* Applies "+name" and "-name" changes to the current reviewers. Plain names replace the whole list */
+func editReviewers(current []api.User, changes []string, candidates []api.User, workspace string) []api.User {
+	result := []api.User{}
+	replace := false
+	for _, change := range util.ExpandReviewerGroups(changes) {
+		if prefix, _ := util.SplitReviewerPrefix(change); prefix == "" {
+			replace = true
+		}
+	}
+	if !replace {
+		result = append(result, current...)
+	}
+
+	// current reviewers are also valid candidates, they may not be workspace members anymore
+	for _, user := range current {
+		candidates = util.AppendUniqueUser(candidates, user)
+	}
+	for _, change := range util.ExpandReviewerGroups(changes) {
+		prefix, bare := util.SplitReviewerPrefix(change)
+		user, err := util.ResolveReviewer(bare, candidates, workspace)
+		cobra.CheckErr(err)
+		if prefix == "-" {
+			filtered := []api.User{}
+			for _, r := range result {
+				if r.AccountId != user.AccountId {
+					filtered = append(filtered, r)
+				}
+			}
+			result = filtered
+		} else {
+			result = util.AppendUniqueUser(result, user)
+		}
+	}
+	return result
+}
+
+func reviewerCompletion(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	repo := util.GetCurrentRepo()
+	if repo == "" {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+	opt := []string{}
+	for group := range viper.GetStringMapStringSlice("reviewer_groups") {
+		opt = append(opt, group)
+	}
+	for _, user := range <-api.GetWorkspaceMembers(strings.Split(repo, "/")[0]) {
+		opt = append(opt, user.Nickname)
+	}
+	return opt, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,253 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var StatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of relevant pull requests",
+	Long: `Show the pull request of the current branch, the pull requests you created and the ones waiting for your review.
+	Use --workspace to look for them across all repositories of a workspace.
+	Pull requests with comments you haven't seen on "pr view" are marked`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		workspace, _ := cmd.Flags().GetString("workspace")
+		user := api.GetUser()
+
+		var currentChannel <-chan api.PullRequest
+		if branch, err := util.GetCurrentBranch(); err == nil && repo != "" {
+			currentChannel = api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN)}, Source: branch, Participants: true})
+		}
+
+		var authored, reviewing []api.PullRequest
+		if workspace == "" {
+			authoredChannel := api.GetPrListByQuery(repo, fmt.Sprintf(`state = "OPEN" AND author.uuid = %s`, api.BBQLString(user.UUID)))
+			reviewingChannel := api.GetPrListByQuery(repo, fmt.Sprintf(`state = "OPEN" AND reviewers.uuid = %s`, api.BBQLString(user.UUID)))
+			authored, reviewing = <-authoredChannel, <-reviewingChannel
+		} else {
+			authoredChannel := api.GetWorkspacePrsByUser(workspace, user.UUID, api.PrListOptions{States: []string{string(api.OPEN)}})
+			reviewing = getWorkspaceReviewQueue(workspace, user)
+			authored = <-authoredChannel
+		}
+		reviewing = awaitingReview(reviewing, user)
+
+		var current *api.PullRequest
+		if currentChannel != nil {
+			if pr, ok := <-currentChannel; ok {
+				current = &pr
+			}
+		}
+
+		// fetch the statuses of all pull requests concurrently
+		all := append(append([]api.PullRequest{}, authored...), reviewing...)
+		if current != nil {
+			all = append(all, *current)
+		}
+		statuses := map[string][]api.CommitStatus{}
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+		for _, pr := range all {
+			wg.Add(1)
+			go func(pr api.PullRequest) {
+				defer wg.Done()
+				prStatuses := <-api.GetPrStatuses(prRepo(pr, repo), pr.ID)
+				mutex.Lock()
+				statuses[prKey(prRepo(pr, repo), pr.ID)] = prStatuses
+				mutex.Unlock()
+			}(pr)
+		}
+		wg.Wait()
+
+		viewed := readViewedPrs()
+		printPr := func(pr api.PullRequest, showAuthor bool) {
+			prRepository := prRepo(pr, repo)
+			util.Printf("  ")
+			if workspace != "" {
+				util.Printf("\033[1;36m%s\033[m ", prRepository)
+			}
+			util.Printf("%s\033[1;32m#%d\033[m %s \033[1;34m[ %s → %s ]\033[m", util.FormatPrDraft(pr.Draft), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name)
+			if showAuthor {
+				util.Printf(" \033[33m%s\033[m", pr.Author.Nickname)
+			}
+			util.Printf("\n    %s\n", formatPrSummary(pr, statuses[prKey(prRepository, pr.ID)], viewed))
+		}
+
+		util.Printf("\n\033[1;37mCurrent branch\033[m\n")
+		if current != nil {
+			printPr(*current, false)
+		} else {
+			util.Printf("  \033[37mThere is no pull request associated with the current branch\033[m\n")
+		}
+
+		util.Printf("\n\033[1;37mCreated by you\033[m\n")
+		sortPrsByUpdate(authored)
+		for _, pr := range authored {
+			printPr(pr, false)
+		}
+		if len(authored) == 0 {
+			util.Printf("  \033[37mYou have no open pull requests\033[m\n")
+		}
+
+		util.Printf("\n\033[1;37mRequesting your review\033[m\n")
+		sortPrsByUpdate(reviewing)
+		for _, pr := range reviewing {
+			printPr(pr, true)
+		}
+		if len(reviewing) == 0 {
+			util.Printf("  \033[37mYou have no pull requests to review\033[m\n")
+		}
+		fmt.Println()
+	},
+}
+
+func init() {
+	StatusCmd.Flags().StringP("workspace", "w", "", "show pull requests from all repositories of a workspace")
+}
+
+/* Queries every repository of the workspace for open pull requests where user is a reviewer */
+func getWorkspaceReviewQueue(workspace string, user api.User) []api.PullRequest {
+	return collectWorkspacePrs(workspace, nil, func(repository string) []api.PullRequest {
+		return <-api.GetPrListByQuery(repository, fmt.Sprintf(`state = "OPEN" AND reviewers.uuid = %s`, api.BBQLString(user.UUID)))
+	})
+}
+
+/* Runs fetch concurrently for the repositories of the workspace matching any of the patterns (all if none is given) and joins the results */
+func collectWorkspacePrs(workspace string, patterns []string, fetch func(repository string) []api.PullRequest) []api.PullRequest {
+	result := []api.PullRequest{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, 10) // don't flood the API
+	for _, repository := range <-api.GetRepositoryList(workspace) {
+		if !matchesRepoPatterns(repository, patterns) {
+			continue
+		}
+		wg.Add(1)
+		go func(repository string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			prs := fetch(repository)
+			<-limit
+			mutex.Lock()
+			result = append(result, prs...)
+			mutex.Unlock()
+		}(repository.FullName)
+	}
+	wg.Wait()
+	return result
+}
+
+func matchesRepoPatterns(repository api.Repository, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, name := range []string{repository.Slug, repository.FullName} {
+			if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* Filters out pull requests the user already approved */
+func awaitingReview(prs []api.PullRequest, user api.User) []api.PullRequest {
+	result := []api.PullRequest{}
+	for _, pr := range prs {
+		approved := false
+		for _, participant := range pr.Participants {
+			if participant.User.UUID == user.UUID && participant.Approved {
+				approved = true
+			}
+		}
+		if !approved {
+			result = append(result, pr)
+		}
+	}
+	return result
+}
+
+func formatPrSummary(pr api.PullRequest, statuses []api.CommitStatus, viewed map[string]int) string {
+	summary := []string{}
+
+	statuses = latestStatuses(statuses)
+	if len(statuses) == 0 {
+		summary = append(summary, "\033[37mNo builds\033[m")
+	} else {
+		state := "SUCCESSFUL"
+		for _, status := range statuses {
+			if status.State == "FAILED" || status.State == "STOPPED" {
+				state = status.State
+				break
+			} else if status.State != "SUCCESSFUL" {
+				state = status.State
+			}
+		}
+		summary = append(summary, fmt.Sprintf("%s %s", util.FormatPipelineStatus(state), strings.ToLower(state)))
+	}
+
+	approvals, changesRequested := 0, 0
+	for _, participant := range pr.Participants {
+		if participant.Approved {
+			approvals++
+		}
+		if participant.State == "changes_requested" {
+			changesRequested++
+		}
+	}
+	if changesRequested > 0 {
+		summary = append(summary, "\033[1;31m✗ Changes requested\033[m")
+	} else if approvals > 0 {
+		summary = append(summary, fmt.Sprintf("\033[1;32m✓ %d approved\033[m", approvals))
+	} else {
+		summary = append(summary, "\033[37mReview required\033[m")
+	}
+
+	seen := viewed[prKey(prRepo(pr, viper.GetString("repo")), pr.ID)]
+	if pr.CommentCount > seen {
+		summary = append(summary, fmt.Sprintf("\033[1;33m%d new comments\033[m", pr.CommentCount-seen))
+	} else if pr.CommentCount > 0 {
+		summary = append(summary, fmt.Sprintf("\033[37m%d comments\033[m", pr.CommentCount))
+	}
+	return strings.Join(summary, " \033[37m-\033[m ")
+}
+
+func sortPrsByUpdate(prs []api.PullRequest) {
+	sort.SliceStable(prs, func(i, j int) bool { return prs[i].UpdatedOn.After(prs[j].UpdatedOn) })
+}
+
+/* Returns the repository of a pull request, falling back to repo when the response doesn't include it */
+func prRepo(pr api.PullRequest, repo string) string {
+	if pr.Destination.Repository.FullName != "" {
+		return pr.Destination.Repository.FullName
+	}
+	return repo
+}
+
+func prKey(repo string, id int) string {
+	return fmt.Sprintf("%s#%d", strings.ToLower(repo), id)
+}
+
+/* Returns the number of comments each pull request had when last viewed */
+func readViewedPrs() map[string]int {
+	viewed := map[string]int{}
+	util.ReadCache("viewed-prs.json", &viewed)
+	return viewed
+}
+
+func markPrViewed(repo string, pr api.PullRequest) {
+	viewed := readViewedPrs()
+	viewed[prKey(prRepo(pr, repo), pr.ID)] = pr.CommentCount
+	util.WriteCache("viewed-prs.json", viewed)
+}
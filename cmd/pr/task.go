@@ -0,0 +1,179 @@
+package pr
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var TaskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "Manage tasks of a pull request",
+	Long: `Manage tasks of a pull request.
+	If no pull request ID is given the pull request of the current branch is used`,
+}
+
+var TaskListCmd = &cobra.Command{
+	Use:               "list [PR]",
+	Short:             "List tasks of a pull request",
+	Aliases:           []string{"ls"},
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: openPrCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args)
+		unresolved, _ := cmd.Flags().GetBool("unresolved")
+
+		count := 0
+		for _, task := range <-api.GetPrTasks(repo, id) {
+			if unresolved && task.State == "RESOLVED" {
+				continue
+			}
+			printTask(task)
+			count++
+		}
+		if count == 0 {
+			util.Printf("No tasks for pull request \033[1;32m#%d\033[m\n", id)
+		}
+	},
+}
+
+var TaskCreateCmd = &cobra.Command{
+	Use:   "create [PR] MESSAGE",
+	Short: "Create a task on a pull request",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id := getPrId(repo, args[:len(args)-1])
+		commentId, _ := cmd.Flags().GetInt("comment")
+
+		body := api.CreateTaskBody{}
+		body.Content.Raw = args[len(args)-1]
+		if commentId != 0 {
+			body.Comment = &api.CommentParent{Id: commentId}
+		}
+		task := api.PostPrTask(repo, id, body)
+		util.Printf("Task \033[1;32m#%d\033[m created on pull request #%d\n", task.Id, id)
+	},
+}
+
+var TaskResolveCmd = &cobra.Command{
+	Use:   "resolve [PR] [TASK-ID]",
+	Short: "Resolve tasks of a pull request",
+	Long: `Resolve tasks of a pull request.
+	If no task ID is given you'll be prompted to choose from the unresolved tasks`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setTasksState(args, "RESOLVED")
+	},
+}
+
+var TaskReopenCmd = &cobra.Command{
+	Use:   "reopen [PR] [TASK-ID]",
+	Short: "Reopen resolved tasks of a pull request",
+	Long: `Reopen resolved tasks of a pull request.
+	If no task ID is given you'll be prompted to choose from the resolved tasks`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setTasksState(args, "UNRESOLVED")
+	},
+}
+
+var TaskDeleteCmd = &cobra.Command{
+	Use:   "delete [PR] [TASK-ID]",
+	Short: "Delete tasks of a pull request",
+	Long: `Delete tasks of a pull request.
+	If no task ID is given you'll be prompted to choose from all tasks`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		id, tasks := selectTasks(repo, args, "")
+		for _, task := range tasks {
+			api.DeletePrTask(repo, id, task.Id)
+			util.Printf("Task #%d \033[1;31mDeleted\033[m\n", task.Id)
+		}
+	},
+}
+
+func init() {
+	TaskCmd.AddCommand(TaskListCmd)
+	TaskCmd.AddCommand(TaskCreateCmd)
+	TaskCmd.AddCommand(TaskResolveCmd)
+	TaskCmd.AddCommand(TaskReopenCmd)
+	TaskCmd.AddCommand(TaskDeleteCmd)
+	TaskListCmd.Flags().BoolP("unresolved", "u", false, "show only unresolved tasks")
+	TaskCreateCmd.Flags().Int("comment", 0, "attach the task to a comment ID")
+}
+
+func printTask(task api.PrTask) {
+	if task.State == "RESOLVED" {
+		util.Printf("\033[1;32m[x]\033[m \033[37m#%d\033[m %s\n", task.Id, task.Content.Raw)
+	} else {
+		util.Printf("\033[1;33m[ ]\033[m \033[37m#%d\033[m %s\n", task.Id, task.Content.Raw)
+	}
+}
+
+func countUnresolvedTasks(tasks []api.PrTask) int {
+	count := 0
+	for _, task := range tasks {
+		if task.State != "RESOLVED" {
+			count++
+		}
+	}
+	return count
+}
+
+/* Returns the pull request ID and the tasks given as arguments, prompting for tasks that are not in state */
+func selectTasks(repo string, args []string, state string) (int, []api.PrTask) {
+	prArgs, taskArg := args, ""
+	if len(args) == 2 {
+		prArgs, taskArg = args[:1], args[1]
+	} else if len(args) == 1 {
+		prArgs, taskArg = []string{}, args[0]
+	}
+	id := getPrId(repo, prArgs)
+	tasks := <-api.GetPrTasks(repo, id)
+
+	if taskArg != "" {
+		taskId, err := strconv.Atoi(strings.TrimPrefix(taskArg, "#"))
+		cobra.CheckErr(err)
+		for _, task := range tasks {
+			if task.Id == taskId {
+				return id, []api.PrTask{task}
+			}
+		}
+		cobra.CheckErr(fmt.Sprintf("Task #%d not found on pull request #%d", taskId, id))
+	}
+
+	candidates := []api.PrTask{}
+	for _, task := range tasks {
+		if task.State != state {
+			candidates = append(candidates, task)
+		}
+	}
+	if len(candidates) == 0 {
+		cobra.CheckErr(fmt.Sprintf("No tasks to choose from on pull request #%d", id))
+	}
+	selected := []api.PrTask{}
+	for _, idx := range util.SelectFZF(candidates, "Tasks > ", func(i int) string {
+		return fmt.Sprintf("#%d %s", candidates[i].Id, candidates[i].Content.Raw)
+	}) {
+		selected = append(selected, candidates[idx])
+	}
+	return id, selected
+}
+
+func setTasksState(args []string, state string) {
+	repo := viper.GetString("repo")
+	id, tasks := selectTasks(repo, args, state)
+	for _, task := range tasks {
+		body := api.CreateTaskBody{State: state}
+		body.Content.Raw = task.Content.Raw
+		printTask(api.UpdatePrTask(repo, id, task.Id, body))
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bb/api"
 	"bb/util"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,7 +20,7 @@ var ViewCmd = &cobra.Command{
 	Args: cobra.MaximumNArgs(1),
 	ValidArgsFunction: func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		var opt = []string{}
-		for pr := range api.GetPrList(util.GetCurrentRepo(), []string{string(api.OPEN)}, "", "", "", "", 1, false, false) {
+		for pr := range api.GetPrList(util.GetCurrentRepo(), api.PrListOptions{States: []string{string(api.OPEN)}}) {
 			opt = append(opt, fmt.Sprint(pr.ID))
 		}
 		return opt, cobra.ShellCompDirectiveDefault
@@ -39,7 +41,7 @@ var ViewCmd = &cobra.Command{
 				cobra.CheckErr(err)
 			}
 			// retrieve id of pr for current branch
-			pr := <-api.GetPrList(repo, []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, "", "", sourceBranch, targetBranch, 1, false, false)
+			pr := <-api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, Source: sourceBranch, Destination: targetBranch})
 			if pr.ID == 0 {
 				cobra.CheckErr(fmt.Sprintf("No pull request found for branches (source: '%s', target: '%s')", sourceBranch, targetBranch))
 			}
@@ -51,11 +53,13 @@ var ViewCmd = &cobra.Command{
 
 		statusesChannel := api.GetPrStatuses(repo, id)
 		commentsChannel := api.GetPrComments(repo, id)
+		tasksChannel := api.GetPrTasks(repo, id)
 
 		// BASIC INFO
 
 		pr := <-api.GetPr(repo, id)
-		util.Printf("\n%s \033[1;32m#%d\033[m \033[1;37m%s\033[m  \033[1;34m[ %s → %s]\033[m\n", util.FormatPrState(pr.State), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name)
+		markPrViewed(repo, pr)
+		util.Printf("\n%s %s\033[1;32m#%d\033[m \033[1;37m%s\033[m  \033[1;34m[ %s → %s]\033[m\n", util.FormatPrState(pr.State), util.FormatPrDraft(pr.Draft), pr.ID, pr.Title, pr.Source.Branch.Name, pr.Destination.Branch.Name)
 		util.Printf("\033[37m  opened by %s, %d comments, last updated: %s\033[m\n", pr.Author.Nickname, pr.CommentCount, util.TimeAgo(pr.UpdatedOn))
 		util.Printf("\033[37m  reviewers: \n")
 		for _, participant := range pr.Participants {
@@ -76,6 +80,18 @@ var ViewCmd = &cobra.Command{
 			return
 		}
 
+		// TASKS
+
+		tasks := <-tasksChannel
+		if len(tasks) > 0 {
+			util.Printf("Tasks: \033[37m(%d/%d resolved)\033[m\n", len(tasks)-countUnresolvedTasks(tasks), len(tasks))
+			for _, task := range tasks {
+				util.Printf("  ")
+				printTask(task)
+			}
+			fmt.Println()
+		}
+
 		// PIPELINES
 
 		pipelines := <-statusesChannel
@@ -89,10 +105,17 @@ var ViewCmd = &cobra.Command{
 		}
 
 		if showComments {
+			unresolved, _ := cmd.Flags().GetBool("unresolved")
 			comments := <-commentsChannel
+			var files []fileDiff
 			for _, comment := range comments {
-				util.Printf("%s %s", comment.Content.Raw, comment.User.DisplayName)
+				if comment.Inline != nil {
+					files = splitDiff(<-api.GetPrDiff(repo, id))
+					break
+				}
 			}
+			fmt.Println("Comments:")
+			printCommentThreads(comments, files, unresolved)
 		}
 
 	},
@@ -104,5 +127,98 @@ func init() {
 	ViewCmd.RegisterFlagCompletionFunc("target", util.BranchCompletion)
 	ViewCmd.RegisterFlagCompletionFunc("source", util.BranchCompletion)
 	ViewCmd.Flags().BoolP("comments", "c", false, "View comments")
+	ViewCmd.Flags().Bool("unresolved", false, "View only unresolved comment threads (with --comments)")
 	ViewCmd.Flags().Bool("web", false, "Open in the browser.")
 }
+
+/* Prints comments grouped by thread, with the changed lines of inline comments */
+func printCommentThreads(comments []api.PrComment, files []fileDiff, unresolved bool) {
+	roots, replies := groupCommentThreads(comments)
+
+	count := 0
+	for _, root := range roots {
+		if unresolved && (root.Resolution != nil || root.Deleted) {
+			continue
+		}
+		count++
+		header := "\033[1;37mGeneral\033[m"
+		if root.Inline != nil {
+			header = fmt.Sprintf("\033[1;34m%s\033[m", root.Inline.Path)
+			if line, _ := commentLine(root); line != 0 {
+				header += fmt.Sprintf("\033[37m:%d\033[m", line)
+			}
+		}
+		if root.Inline != nil && root.Inline.Outdated {
+			header += " \033[33m(outdated)\033[m"
+		}
+		if root.Resolution != nil {
+			header += fmt.Sprintf(" \033[32m✓ resolved by %s\033[m", root.Resolution.User.DisplayName)
+		}
+		util.Printf("● %s\n", header)
+		if root.Inline != nil && !root.Inline.Outdated {
+			if line, oldSide := commentLine(root); line != 0 {
+				for _, file := range files {
+					if file.Path == root.Inline.Path || file.OldPath == root.Inline.Path {
+						for _, text := range diffContext(file, line, oldSide, 4) {
+							util.Printf("  \033[37m│\033[m %s\n", strings.TrimRight(colorizeDiff(text), "\n"))
+						}
+						break
+					}
+				}
+			}
+		}
+		printComment(root, replies, 1)
+		fmt.Println()
+	}
+	if count == 0 {
+		util.Printf("No comments\n")
+	}
+}
+
+/* Returns the comments starting a thread, oldest first, and the replies to each comment */
+func groupCommentThreads(comments []api.PrComment) ([]api.PrComment, map[int][]api.PrComment) {
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedOn.Before(comments[j].CreatedOn) })
+	byId := map[int]bool{}
+	for _, comment := range comments {
+		byId[comment.Id] = true
+	}
+	replies := map[int][]api.PrComment{}
+	roots := []api.PrComment{}
+	for _, comment := range comments {
+		if comment.Parent != nil && byId[comment.Parent.Id] {
+			replies[comment.Parent.Id] = append(replies[comment.Parent.Id], comment)
+		} else {
+			roots = append(roots, comment)
+		}
+	}
+	return roots, replies
+}
+
+func printComment(comment api.PrComment, replies map[int][]api.PrComment, depth int) {
+	indent := strings.Repeat("  ", depth)
+	prefix := ""
+	if depth > 1 {
+		prefix = "↳ "
+	}
+	util.Printf("%s%s\033[1;33m%s\033[m \033[37m%s #%d\033[m\n", indent, prefix, comment.User.DisplayName, util.TimeAgo(comment.CreatedOn), comment.Id)
+	content := comment.Content.Raw
+	if comment.Deleted {
+		content = "\033[37m[deleted]\033[m"
+	}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		util.Printf("%s  %s\n", indent, line)
+	}
+	for _, reply := range replies[comment.Id] {
+		printComment(reply, replies, depth+1)
+	}
+}
+
+/* Returns the line an inline comment refers to and whether it is on the old version of the file */
+func commentLine(comment api.PrComment) (int, bool) {
+	if comment.Inline.To != nil {
+		return *comment.Inline.To, false
+	} else if comment.Inline.From != nil {
+		return *comment.Inline.From, true
+	}
+	return 0, false
+}
@@ -0,0 +1,29 @@
+package repo
+
+import (
+	"bb/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var RepoCmd = &cobra.Command{
+	Use:     "repo",
+	Aliases: []string{"repository"},
+	Short:   "Manage repository settings [repository]",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlag("repo", cmd.Flags().Lookup("repo"))
+		cobra.CheckErr(err)
+		if curRepo := util.GetCurrentRepo(); curRepo != "" {
+			viper.SetDefault("repo", curRepo)
+		}
+		if !viper.IsSet("repo") {
+			cobra.CheckErr("repo is not defined")
+		}
+	},
+}
+
+func init() {
+	RepoCmd.AddCommand(DefaultReviewersCmd)
+	RepoCmd.PersistentFlags().StringP("repo", "R", "", "selected repository")
+}
@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var DefaultReviewersCmd = &cobra.Command{
+	Use:     "default-reviewers",
+	Aliases: []string{"reviewers"},
+	Short:   "Manage the default reviewers of a repository [reviewers]",
+}
+
+var ListDefaultReviewersCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List default reviewers of a repository",
+	Long:    "List default reviewers of a repository, including the ones inherited from its project",
+	Aliases: []string{"ls"},
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		reviewers := <-api.GetEffectiveDefaultReviewers(repo)
+		for _, reviewer := range reviewers {
+			util.Printf("%s \033[37m(%s)\033[m", reviewer.User.DisplayName, reviewer.User.Nickname)
+			if reviewer.ReviewerType == "project" {
+				util.Printf(" \033[1;34m[ project ]\033[m")
+			} else {
+				util.Printf(" \033[1;36m[ repository ]\033[m")
+			}
+			fmt.Println()
+		}
+		if len(reviewers) == 0 {
+			util.Printf("No default reviewers for \033[1;36m%s\033[m\n", repo)
+		}
+	},
+}
+
+var AddDefaultReviewersCmd = &cobra.Command{
+	Use:   "add [USER]...",
+	Short: "Add default reviewers to a repository",
+	Long: `Add default reviewers to a repository by nickname, display name, account id or email.
+	Names of groups defined in "reviewer_groups" of your config file are expanded to their members.
+	If no user is given they can be selected from the workspace members`,
+	ValidArgsFunction: memberCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		workspace := strings.Split(repo, "/")[0]
+		currentChannel := api.GetEffectiveDefaultReviewers(repo)
+		members := <-api.GetWorkspaceMembers(workspace)
+		current := map[string]api.DefaultReviewer{}
+		for _, reviewer := range <-currentChannel {
+			current[reviewer.User.UUID] = reviewer
+		}
+
+		var users []api.User
+		if len(args) > 0 {
+			users = util.ResolveReviewers(args, members, workspace)
+		} else {
+			candidates := []api.User{}
+			for _, member := range members {
+				if reviewer, ok := current[member.UUID]; !ok || reviewer.ReviewerType != "repository" {
+					candidates = append(candidates, member)
+				}
+			}
+			users = selectUsers(candidates)
+		}
+
+		failed := false
+		for _, user := range users {
+			if reviewer, ok := current[user.UUID]; ok && reviewer.ReviewerType == "repository" {
+				util.Printf("\033[1;37m%s\033[m is already a default reviewer\n", user.DisplayName)
+				continue
+			}
+			if err := api.AddDefaultReviewer(repo, user); err != nil {
+				util.Printf("\033[1;31m✗\033[m Could not add \033[1;37m%s\033[m: %s\n", user.DisplayName, err)
+				failed = true
+				continue
+			}
+			util.Printf("\033[1;32m✓\033[m Added \033[1;37m%s\033[m to the default reviewers\n", user.DisplayName)
+		}
+		if failed {
+			cobra.CheckErr("Some default reviewers could not be added")
+		}
+	},
+}
+
+var RemoveDefaultReviewersCmd = &cobra.Command{
+	Use:     "remove [USER]...",
+	Short:   "Remove default reviewers from a repository",
+	Long:    "Remove default reviewers from a repository. Reviewers inherited from the project can only be removed on the project settings",
+	Aliases: []string{"rm"},
+	ValidArgsFunction: func(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		opt := []string{}
+		for _, reviewer := range <-api.GetEffectiveDefaultReviewers(util.GetCurrentRepo()) {
+			if reviewer.ReviewerType == "repository" {
+				opt = append(opt, fmt.Sprintf("%s\t%s", reviewer.User.Nickname, reviewer.User.DisplayName))
+			}
+		}
+		return opt, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		reviewers := <-api.GetEffectiveDefaultReviewers(repo)
+		repoReviewers := []api.User{}
+		inherited := map[string]bool{}
+		all := []api.User{}
+		for _, reviewer := range reviewers {
+			all = append(all, reviewer.User)
+			if reviewer.ReviewerType == "repository" {
+				repoReviewers = append(repoReviewers, reviewer.User)
+			} else {
+				inherited[reviewer.User.UUID] = true
+			}
+		}
+
+		var users []api.User
+		if len(args) > 0 {
+			users = util.ResolveReviewers(args, all, strings.Split(repo, "/")[0])
+		} else {
+			users = selectUsers(repoReviewers)
+		}
+
+		failed := false
+		for _, user := range users {
+			if inherited[user.UUID] {
+				util.Printf("\033[1;31m✗\033[m \033[1;37m%s\033[m is inherited from the project, remove it on the project settings\n", user.DisplayName)
+				failed = true
+				continue
+			}
+			if err := api.RemoveDefaultReviewer(repo, user); err != nil {
+				util.Printf("\033[1;31m✗\033[m Could not remove \033[1;37m%s\033[m: %s\n", user.DisplayName, err)
+				failed = true
+				continue
+			}
+			util.Printf("\033[1;32m✓\033[m Removed \033[1;37m%s\033[m from the default reviewers\n", user.DisplayName)
+		}
+		if failed {
+			cobra.CheckErr("Some default reviewers could not be removed")
+		}
+	},
+}
+
+func init() {
+	DefaultReviewersCmd.AddCommand(ListDefaultReviewersCmd)
+	DefaultReviewersCmd.AddCommand(AddDefaultReviewersCmd)
+	DefaultReviewersCmd.AddCommand(RemoveDefaultReviewersCmd)
+}
+
+func selectUsers(users []api.User) []api.User {
+	selected := []api.User{}
+	for _, idx := range util.SelectFZF(users, "Reviewers > ", func(i int) string {
+		return fmt.Sprintf("%s \033[37m(%s)\033[m", users[i].DisplayName, users[i].Nickname)
+	}) {
+		selected = append(selected, users[idx])
+	}
+	return selected
+}
+
+func memberCompletion(comd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	repo := util.GetCurrentRepo()
+	if repo == "" {
+		return []string{}, cobra.ShellCompDirectiveNoFileComp
+	}
+	opt := []string{}
+	for _, user := range <-api.GetWorkspaceMembers(strings.Split(repo, "/")[0]) {
+		opt = append(opt, fmt.Sprintf("%s\t%s", user.Nickname, user.DisplayName))
+	}
+	return opt, cobra.ShellCompDirectiveNoFileComp
+}
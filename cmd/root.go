@@ -8,6 +8,8 @@ import (
 	"bb/cmd/issue"
 	"bb/cmd/pipeline"
 	"bb/cmd/pr"
+	"bb/cmd/repo"
+	"bb/cmd/stack"
 	"bb/cmd/tempo"
 	"bb/store"
 	"os"
@@ -41,6 +43,8 @@ func init() {
 
 	RootCmd.AddCommand(auth.AuthCmd)
 	RootCmd.AddCommand(pr.PrCmd)
+	RootCmd.AddCommand(stack.StackCmd)
+	RootCmd.AddCommand(repo.RepoCmd)
 	RootCmd.AddCommand(environment.EnvironmentCmd)
 	RootCmd.AddCommand(issue.IssueCmd)
 	RootCmd.AddCommand(tempo.TempoCmd)
@@ -0,0 +1,34 @@
+package stack
+
+import (
+	"bb/util"
+
+	"github.com/spf13/cobra"
+)
+
+var BranchCmd = &cobra.Command{
+	Use:   "branch NAME",
+	Short: "Create a new branch stacked on the current one",
+	Long: `Create and checkout a new branch on top of the current branch (or the one given with --parent)
+	and record it as its parent`,
+	Aliases: []string{"new"},
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		parent, _ := cmd.Flags().GetString("parent")
+		if parent == "" {
+			var err error
+			parent, err = util.GetCurrentBranch()
+			cobra.CheckErr(err)
+		}
+		_, err := util.GitRaw("checkout", "-b", args[0], parent)
+		cobra.CheckErr(err)
+		setParent(args[0], parent)
+		recordBase(args[0], parent)
+		util.Printf("Created branch \033[1;34m%s\033[m on top of \033[1;34m%s\033[m\n", args[0], parent)
+	},
+}
+
+func init() {
+	BranchCmd.Flags().StringP("parent", "p", "", "parent branch. Defaults to the current branch")
+	BranchCmd.RegisterFlagCompletionFunc("parent", util.BranchCompletion)
+}
@@ -0,0 +1,18 @@
+package stack
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var PushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Push the branches of the stack to origin",
+	Long: `Push every branch of the current stack that differs from origin.
+	Branches are force pushed with lease since they are usually rebased`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		parents := getParents()
+		_, root := currentStack(parents)
+		pushStack(stackBranches(root, parents))
+	},
+}
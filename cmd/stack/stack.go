@@ -0,0 +1,162 @@
+package stack
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var StackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Manage stacked branches and their pull requests",
+	Long: `Manage branches stacked on top of each other (ex: main → feature-a → feature-b).
+	The parent of each branch is recorded in the git config of the repository`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlag("repo", cmd.Flags().Lookup("repo"))
+		cobra.CheckErr(err)
+		if curRepo := util.GetCurrentRepo(); curRepo != "" {
+			viper.SetDefault("repo", curRepo)
+		}
+		if !viper.IsSet("repo") {
+			cobra.CheckErr("repo is not defined")
+		}
+	},
+}
+
+func init() {
+	StackCmd.AddCommand(BranchCmd)
+	StackCmd.AddCommand(TrackCmd)
+	StackCmd.AddCommand(UntrackCmd)
+	StackCmd.AddCommand(ViewCmd)
+	StackCmd.AddCommand(SubmitCmd)
+	StackCmd.AddCommand(SyncCmd)
+	StackCmd.AddCommand(PushCmd)
+	StackCmd.PersistentFlags().StringP("repo", "R", "", "selected repository")
+}
+
+func parentKey(branch string) string {
+	return fmt.Sprintf("branch.%s.bb-parent", branch)
+}
+
+/* the commit of the parent the branch was last based on, used to rebase only the branch's own commits */
+func baseKey(branch string) string {
+	return fmt.Sprintf("branch.%s.bb-base", branch)
+}
+
+/* Returns the parent of every tracked branch */
+func getParents() map[string]string {
+	parents := map[string]string{}
+	for key, parent := range util.GetGitConfigRegexp(`^branch\..*\.bb-parent$`) {
+		parents[strings.TrimSuffix(strings.TrimPrefix(key, "branch."), ".bb-parent")] = parent
+	}
+	return parents
+}
+
+func setParent(branch string, parent string) {
+	cobra.CheckErr(util.SetGitConfig(parentKey(branch), parent))
+}
+
+func recordBase(branch string, ref string) {
+	hash, err := util.GetRefHash(ref)
+	cobra.CheckErr(err)
+	cobra.CheckErr(util.SetGitConfig(baseKey(branch), hash))
+}
+
+/* Returns the recorded base of the branch, or where it forked from parent if there is none */
+func getBase(branch string, parent string) string {
+	if base := util.GetGitConfig(baseKey(branch)); base != "" && util.IsAncestor(base, branch) {
+		return base
+	}
+	base, err := util.MergeBase(parent, branch)
+	cobra.CheckErr(err)
+	return base
+}
+
+func untrack(branch string) {
+	cobra.CheckErr(util.UnsetGitConfig(parentKey(branch)))
+	cobra.CheckErr(util.UnsetGitConfig(baseKey(branch)))
+}
+
+/* Follows the parents of branch up to the branch that isn't tracked */
+func stackRoot(branch string, parents map[string]string) string {
+	seen := map[string]bool{}
+	for parents[branch] != "" && !seen[branch] {
+		seen[branch] = true
+		branch = parents[branch]
+	}
+	return branch
+}
+
+func childrenOf(branch string, parents map[string]string) []string {
+	children := []string{}
+	for child, parent := range parents {
+		if parent == branch {
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+/* Returns the branches stacked on root, parents before their children */
+func stackBranches(root string, parents map[string]string) []string {
+	branches := []string{}
+	for _, child := range childrenOf(root, parents) {
+		branches = append(branches, child)
+		branches = append(branches, stackBranches(child, parents)...)
+	}
+	return branches
+}
+
+/* Returns the current branch and the root of its stack, failing if it isn't part of one */
+func currentStack(parents map[string]string) (string, string) {
+	current, err := util.GetCurrentBranch()
+	cobra.CheckErr(err)
+	root := stackRoot(current, parents)
+	if root == current && len(childrenOf(current, parents)) == 0 {
+		cobra.CheckErr(fmt.Sprintf("Branch '%s' is not part of a stack. Use \"bb stack branch\" or \"bb stack track\" to create one", current))
+	}
+	return current, root
+}
+
+/* Returns the most recent pull request with branch as source */
+func latestPr(repo string, branch string) api.PullRequest {
+	return <-api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, Source: branch})
+}
+
+/* Fetches the latest pull request of each branch concurrently */
+func latestPrs(repo string, branches []string) map[string]api.PullRequest {
+	channels := map[string]<-chan api.PullRequest{}
+	for _, branch := range branches {
+		channels[branch] = api.GetPrList(repo, api.PrListOptions{States: []string{string(api.OPEN), string(api.MERGED), string(api.DECLINED), string(api.SUPERSEDED)}, Source: branch, Participants: true})
+	}
+	prs := map[string]api.PullRequest{}
+	for branch, channel := range channels {
+		prs[branch] = <-channel
+		for range channel {
+			// drain the remaining results so the request finishes
+		}
+	}
+	return prs
+}
+
+func retargetPr(repo string, pr api.PullRequest, target string) api.PullRequest {
+	// listed pull requests don't include the reviewers, which the update would remove
+	body := api.PrUpdateBody(<-api.GetPr(repo, pr.ID))
+	body.Destination = &api.Branch{}
+	body.Destination.Branch.Name = target
+	return api.UpdatePr(repo, pr.ID, body)
+}
+
+func requireCleanWorktree() {
+	output, err := util.GitRaw("status", "--porcelain", "--untracked-files=no")
+	cobra.CheckErr(err)
+	if strings.TrimSpace(output) != "" {
+		cobra.CheckErr("You have uncommitted changes, commit or stash them first")
+	}
+}
@@ -0,0 +1,59 @@
+package stack
+
+import (
+	"bb/api"
+	"bb/util"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var SubmitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Create pull requests for the branches of the stack",
+	Long: `Create a pull request targeting its parent for each branch of the current stack that doesn't have one open.
+	Open pull requests targeting another branch are retargeted to the parent`,
+	Aliases: []string{"pr"},
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		parents := getParents()
+		_, root := currentStack(parents)
+		branches := stackBranches(root, parents)
+		prs := latestPrs(repo, branches)
+
+		fill, _ := cmd.Flags().GetBool("fill")
+		yes, _ := cmd.Flags().GetBool("yes")
+		draft, _ := cmd.Flags().GetBool("draft")
+
+		for _, branch := range branches {
+			existing := prs[branch]
+			if strings.EqualFold(string(existing.State), string(api.OPEN)) {
+				if existing.Destination.Branch.Name != parents[branch] {
+					retargetPr(repo, existing, parents[branch])
+					util.Printf("Pull request \033[1;32m#%d\033[m retargeted to \033[1;34m%s\033[m\n", existing.ID, parents[branch])
+				} else {
+					util.Printf("Pull request \033[1;32m#%d\033[m already open for \033[1;34m%s\033[m\n", existing.ID, branch)
+				}
+				continue
+			}
+			util.Printf("\nCreating pull request for \033[1;34m%s\033[m → \033[1;34m%s\033[m\n", branch, parents[branch])
+			util.CreatePullRequest(repo, util.CreatePrOptions{
+				Source:            branch,
+				Target:            parents[branch],
+				Fill:              fill,
+				Draft:             draft,
+				Yes:               yes,
+				CloseSource:       true,
+				IncludeBranchName: viper.GetBool("include_branch_name"),
+			})
+		}
+	},
+}
+
+func init() {
+	SubmitCmd.Flags().BoolP("fill", "f", false, "use the commits of each branch to fill the title and description")
+	SubmitCmd.Flags().BoolP("yes", "y", false, "create the pull requests without any prompts or reviewer selection")
+	SubmitCmd.Flags().BoolP("draft", "d", false, "create the pull requests as drafts")
+}
@@ -0,0 +1,105 @@
+package stack
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var SyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Rebase the stack after its base or one of its branches changed",
+	Long: `Fetch origin and rebase every branch of the current stack on top of its parent.
+	When the pull request of a branch was merged its children are moved to its parent, their pull requests are retargeted and the branch leaves the stack`,
+	Aliases: []string{"rebase"},
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		noFetch, _ := cmd.Flags().GetBool("no-fetch")
+		pushBranches, _ := cmd.Flags().GetBool("push")
+		requireCleanWorktree()
+
+		parents := getParents()
+		current, root := currentStack(parents)
+		if !noFetch {
+			util.Printf("Fetching origin...\n")
+			cobra.CheckErr(util.FetchRemote("origin"))
+		}
+
+		// move the children of merged branches to their parent
+		prs := latestPrs(repo, stackBranches(root, parents))
+		for _, branch := range stackBranches(root, parents) {
+			if !strings.EqualFold(string(prs[branch].State), string(api.MERGED)) {
+				continue
+			}
+			parent := parents[branch]
+			util.Printf("Pull request \033[1;32m#%d\033[m of \033[1;34m%s\033[m was merged\n", prs[branch].ID, branch)
+			for _, child := range childrenOf(branch, parents) {
+				// the recorded base is kept so only the child's own commits are rebased
+				setParent(child, parent)
+				parents[child] = parent
+				if childPr := prs[child]; strings.EqualFold(string(childPr.State), string(api.OPEN)) {
+					retargetPr(repo, childPr, parent)
+					util.Printf("  pull request \033[1;32m#%d\033[m of \033[1;34m%s\033[m retargeted to \033[1;34m%s\033[m\n", childPr.ID, child, parent)
+				}
+			}
+			untrack(branch)
+			delete(parents, branch)
+		}
+
+		rebased := []string{}
+		for _, branch := range stackBranches(root, parents) {
+			onto := parentRef(parents[branch], parents)
+			if util.IsAncestor(onto, branch) {
+				recordBase(branch, onto)
+				continue
+			}
+			util.Printf("Rebasing \033[1;34m%s\033[m on \033[1;34m%s\033[m\n", branch, onto)
+			if err := util.RebaseOnto(onto, getBase(branch, onto), branch); err != nil {
+				util.Printf("\033[1;31mConflicts rebasing %s:\033[m\n%s\n", branch, err)
+				cobra.CheckErr("Resolve them and run \"git rebase --continue\", then run \"bb stack sync\" again")
+			}
+			recordBase(branch, onto)
+			rebased = append(rebased, branch)
+		}
+
+		if util.RefExists("refs/heads/" + current) {
+			cobra.CheckErr(util.CheckoutBranch(current, false))
+		} else {
+			cobra.CheckErr(util.CheckoutBranch(root, false))
+		}
+		if len(rebased) == 0 {
+			util.Printf("Stack is up to date\n")
+		}
+
+		if pushBranches {
+			pushStack(stackBranches(root, parents))
+		} else if len(rebased) > 0 {
+			util.Printf("Run \"bb stack push\" to update the %d rebased branches on origin\n", len(rebased))
+		}
+	},
+}
+
+func init() {
+	SyncCmd.Flags().Bool("no-fetch", false, "don't fetch origin before rebasing")
+	SyncCmd.Flags().BoolP("push", "p", false, "push the branches of the stack after rebasing")
+}
+
+func pushStack(branches []string) {
+	for _, branch := range branches {
+		localHash, err := util.GetRefHash("refs/heads/" + branch)
+		cobra.CheckErr(err)
+		remoteHash, _ := util.GetRefHash("refs/remotes/origin/" + branch)
+		if localHash == remoteHash {
+			continue
+		}
+		util.Printf("Pushing \033[1;34m%s\033[m to origin...\n", branch)
+		if err := util.PushBranch(branch, true); err != nil {
+			cobra.CheckErr(fmt.Sprintf("Could not push '%s': %s", branch, err))
+		}
+	}
+}
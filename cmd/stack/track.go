@@ -0,0 +1,89 @@
+package stack
+
+import (
+	"bb/util"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var TrackCmd = &cobra.Command{
+	Use:   "track [BRANCH]",
+	Short: "Record the parent of an existing branch",
+	Long: `Record the parent of an existing branch, by default the current one.
+	If no parent is given with --parent you'll be prompted to choose one`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: util.BranchCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		branch := branchArg(args)
+		parent, _ := cmd.Flags().GetString("parent")
+		if parent == "" {
+			candidates := []string{}
+			for _, b := range util.ListBranches() {
+				if b != branch && b != "" {
+					candidates = append(candidates, b)
+				}
+			}
+			selected := util.SelectFZF(candidates, fmt.Sprintf("Parent of %s > ", branch), func(i int) string { return candidates[i] })
+			if len(selected) == 0 {
+				return
+			}
+			parent = candidates[selected[0]]
+		}
+		if !util.RefExists(parent) {
+			cobra.CheckErr(fmt.Sprintf("Branch '%s' doesn't exist", parent))
+		}
+
+		parents := getParents()
+		parents[branch] = parent
+		if stackRoot(branch, parents) == branch {
+			cobra.CheckErr(fmt.Sprintf("'%s' can't be the parent of '%s' since it is stacked on it", parent, branch))
+		}
+		setParent(branch, parent)
+		base, err := util.MergeBase(parent, branch)
+		cobra.CheckErr(err)
+		recordBase(branch, base)
+		util.Printf("Branch \033[1;34m%s\033[m is now stacked on \033[1;34m%s\033[m\n", branch, parent)
+	},
+}
+
+var UntrackCmd = &cobra.Command{
+	Use:   "untrack [BRANCH]",
+	Short: "Remove a branch from its stack",
+	Long: `Remove a branch from its stack, by default the current one.
+	Branches stacked on it are moved to its parent`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: util.BranchCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		branch := branchArg(args)
+		parents := getParents()
+		parent, ok := parents[branch]
+		if !ok {
+			cobra.CheckErr(fmt.Sprintf("Branch '%s' is not tracked", branch))
+		}
+		for _, child := range childrenOf(branch, parents) {
+			setParent(child, parent)
+			// keep the commits of the removed branch in its children
+			base, err := util.MergeBase(parent, child)
+			cobra.CheckErr(err)
+			recordBase(child, base)
+			util.Printf("Branch \033[1;34m%s\033[m is now stacked on \033[1;34m%s\033[m\n", child, parent)
+		}
+		untrack(branch)
+		util.Printf("Branch \033[1;34m%s\033[m removed from the stack\n", branch)
+	},
+}
+
+func init() {
+	TrackCmd.Flags().StringP("parent", "p", "", "parent branch")
+	TrackCmd.RegisterFlagCompletionFunc("parent", util.BranchCompletion)
+}
+
+func branchArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	branch, err := util.GetCurrentBranch()
+	cobra.CheckErr(err)
+	return branch
+}
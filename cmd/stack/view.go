@@ -0,0 +1,73 @@
+package stack
+
+import (
+	"bb/api"
+	"bb/util"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ViewCmd = &cobra.Command{
+	Use:     "view",
+	Short:   "Show the stack of the current branch with the state of each pull request",
+	Aliases: []string{"show", "ls"},
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := viper.GetString("repo")
+		parents := getParents()
+		current, root := currentStack(parents)
+		prs := latestPrs(repo, stackBranches(root, parents))
+
+		util.Printf("\033[1;34m%s\033[m\n", root)
+		printStackTree(root, "", current, parents, prs)
+	},
+}
+
+func printStackTree(branch string, indent string, current string, parents map[string]string, prs map[string]api.PullRequest) {
+	children := childrenOf(branch, parents)
+	for i, child := range children {
+		connector, childIndent := "├─", "│  "
+		if i == len(children)-1 {
+			connector, childIndent = "└─", "   "
+		}
+		marker := " "
+		if child == current {
+			marker = "\033[1;32m*\033[m"
+		}
+		util.Printf("%s%s%s \033[1;34m%s\033[m", indent, connector, marker, child)
+
+		if !util.IsAncestor(parentRef(branch, parents), child) {
+			util.Printf(" \033[1;33m(needs sync)\033[m")
+		}
+		if pr := prs[child]; pr.ID != 0 {
+			util.Printf("  %s %s\033[1;32m#%d\033[m %s", util.FormatPrState(pr.State), util.FormatPrDraft(pr.Draft), pr.ID, pr.Title)
+			approvals := 0
+			for _, participant := range pr.Participants {
+				if participant.Approved {
+					approvals++
+				}
+			}
+			if approvals > 0 {
+				util.Printf(" \033[1;32m✓ %d\033[m", approvals)
+			}
+			if strings.EqualFold(string(pr.State), string(api.OPEN)) && pr.Destination.Branch.Name != branch {
+				util.Printf(" \033[1;31m(targets %s)\033[m", pr.Destination.Branch.Name)
+			}
+		} else {
+			util.Printf("  \033[37mno pull request\033[m")
+		}
+		fmt.Println()
+		printStackTree(child, indent+childIndent, current, parents, prs)
+	}
+}
+
+/* Returns the ref to stack on top of, the remote branch when the parent is the base of the stack */
+func parentRef(parent string, parents map[string]string) string {
+	if _, tracked := parents[parent]; tracked {
+		return parent
+	}
+	return util.RemoteOrLocalRef(parent)
+}
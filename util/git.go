@@ -2,15 +2,39 @@ package util
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ldez/go-git-cmd-wrapper/v2/branch"
+	"github.com/ldez/go-git-cmd-wrapper/v2/checkout"
+	"github.com/ldez/go-git-cmd-wrapper/v2/config"
+	"github.com/ldez/go-git-cmd-wrapper/v2/fetch"
 	"github.com/ldez/go-git-cmd-wrapper/v2/git"
+	"github.com/ldez/go-git-cmd-wrapper/v2/merge"
+	"github.com/ldez/go-git-cmd-wrapper/v2/push"
+	"github.com/ldez/go-git-cmd-wrapper/v2/rebase"
 	"github.com/ldez/go-git-cmd-wrapper/v2/remote"
+	"github.com/ldez/go-git-cmd-wrapper/v2/revparse"
+	"github.com/ldez/go-git-cmd-wrapper/v2/types"
 	"github.com/spf13/cobra"
 )
 
+type GitCommit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string
+	Body    string
+}
+
+type GitAuthor struct {
+	Name  string
+	Email string
+}
+
 func GetCurrentRepo() string {
 	url, err := git.Remote(remote.GetURL("origin"))
 	if err != nil {
@@ -44,3 +68,348 @@ func ListBranches() []string {
 	}
 	return branches
 }
+
+/* Runs any git command with the given arguments. On failure the error holds the command output */
+func GitRaw(command string, args ...string) (string, error) {
+	output, err := git.Raw(command, func(g *types.Cmd) {
+		for _, arg := range args {
+			g.AddOptions(arg)
+		}
+	})
+	if err != nil {
+		err = errors.New(strings.TrimSpace(output))
+	}
+	return output, err
+}
+
+func GetRepoRoot() (string, error) {
+	output, err := git.RevParse(revparse.ShowToplevel)
+	if err != nil {
+		err = errors.New(output)
+	}
+	return strings.Trim(output, "\n"), err
+}
+
+func RefExists(ref string) bool {
+	_, err := git.RevParse(revparse.Verify, revparse.Quiet, revparse.Args(ref))
+	return err == nil
+}
+
+/* Returns the remote tracking ref for branch if it exists locally, otherwise the branch itself */
+func RemoteOrLocalRef(branch string) string {
+	if RefExists("origin/" + branch) {
+		return "origin/" + branch
+	}
+	return branch
+}
+
+/* Lists commits reachable from head but not from base, oldest first */
+func ListCommits(base string, head string) ([]GitCommit, error) {
+	output, err := GitRaw("log", "--reverse", "--format=%H%x1f%an%x1f%aI%x1f%s%x1f%b%x1e", base+".."+head, "--")
+	if err != nil {
+		return nil, err
+	}
+	commits := []GitCommit{}
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, GitCommit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    date,
+			Subject: fields[3],
+			Body:    strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
+
+/* Returns the hash of branch on the remote or an empty string if the branch doesn't exist there */
+func RemoteBranchHash(remoteName string, branch string) (string, error) {
+	output, err := GitRaw("ls-remote", "--heads", remoteName, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+func GetRefHash(ref string) (string, error) {
+	output, err := git.RevParse(revparse.Verify, revparse.Args(ref))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return strings.Trim(output, "\n"), err
+}
+
+func IsAncestor(ancestor string, ref string) bool {
+	_, err := GitRaw("merge-base", "--is-ancestor", ancestor, ref)
+	return err == nil
+}
+
+/* Merges head into base without touching the worktree and returns the files with conflicts */
+func TrialMerge(base string, head string) ([]string, error) {
+	output, err := GitRaw("merge-tree", "--write-tree", "--name-only", "--no-messages", base, head)
+	if err == nil {
+		return []string{}, nil
+	}
+	// on conflicts the first line is the resulting tree followed by the conflicting files
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > 1 && regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString(lines[0]) {
+		return lines[1:], nil
+	}
+	return nil, err
+}
+
+func CountCommits(base string, head string) (int, error) {
+	output, err := GitRaw("rev-list", "--count", base+".."+head, "--")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(output))
+}
+
+/* Pushes branch to origin and sets it as the upstream */
+func PushBranch(branch string, force bool) error {
+	output, err := git.Push(push.SetUpstream, git.Cond(force, push.ForceWithLease), push.Remote("origin"), push.RefSpec(branch))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+func BitbucketRemoteURL(repository string) string {
+	return "git@bitbucket.org:" + repository + ".git"
+}
+
+/* Returns the name of a remote pointing to repository, adding one named after its workspace if none exists */
+func EnsureRemote(repository string) (string, error) {
+	output, err := git.Remote(remote.Verbose)
+	if err != nil {
+		return "", errors.New(output)
+	}
+	urlPattern := regexp.MustCompile(`bitbucket\.org[:/]([^/]+/[^/]+?)(\.git)?/?$`)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if match := urlPattern.FindStringSubmatch(fields[1]); match != nil && strings.EqualFold(match[1], repository) {
+			return fields[0], nil
+		}
+	}
+	name := strings.Split(repository, "/")[0]
+	output, err = git.Remote(remote.Add(name, BitbucketRemoteURL(repository)))
+	if err != nil {
+		return "", errors.New(output)
+	}
+	return name, nil
+}
+
+/* Fetches branch from remoteName into its remote tracking ref */
+func FetchBranch(remoteName string, branchName string) error {
+	output, err := git.Fetch(fetch.Remote(remoteName), fetch.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branchName, remoteName, branchName)))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+/* Returns the upstream of a local branch, ex: "origin/main" */
+func GetUpstream(branchName string) string {
+	output, err := GitRaw("rev-parse", "--abbrev-ref", branchName+"@{upstream}")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+func CheckoutBranch(branchName string, force bool) error {
+	output, err := git.Checkout(git.Cond(force, checkout.Force), checkout.Branch(branchName))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+func CheckoutNewBranch(branchName string, startPoint string) error {
+	output, err := git.Checkout(checkout.Track, checkout.NewBranch(branchName), checkout.StartPoint(startPoint))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+func CheckoutDetached(ref string) error {
+	output, err := git.Checkout(checkout.Detach, checkout.Branch(ref))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+/* Moves the current branch to ref, only if it is a fast-forward unless hard is set */
+func UpdateCurrentBranch(ref string, hard bool) error {
+	var output string
+	var err error
+	if hard {
+		output, err = GitRaw("reset", "--hard", ref)
+	} else {
+		output, err = git.Merge(merge.FfOnly, merge.Commits(ref))
+	}
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+/* Points a branch that isn't checked out to ref */
+func SetBranchRef(branchName string, ref string) error {
+	output, err := git.Branch(branch.Force, branch.BranchName(branchName), func(g *types.Cmd) { g.AddOptions(ref) })
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+/* Returns the value of a key in the repository git config, empty if it isn't set */
+func GetGitConfig(key string) string {
+	output, err := git.Config(config.Get(key, ""))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+/* Returns the keys matching the regular expression in the repository git config with their values */
+func GetGitConfigRegexp(keyRegex string) map[string]string {
+	values := map[string]string{}
+	output, err := git.Config(config.GetRegexp(keyRegex, ""))
+	if err != nil {
+		return values // no keys match
+	}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if key, value, found := strings.Cut(line, " "); found {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func SetGitConfig(key string, value string) error {
+	output, err := git.Config(config.Local, config.Entry(key, value))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+func UnsetGitConfig(key string) error {
+	output, err := git.Config(config.Local, config.Unset(key, ""))
+	if err != nil && GetGitConfig(key) != "" {
+		return errors.New(output)
+	}
+	return nil
+}
+
+func MergeBase(a string, b string) (string, error) {
+	output, err := GitRaw("merge-base", a, b)
+	return strings.TrimSpace(output), err
+}
+
+/* Moves the commits of branch after upstream on top of onto. On conflicts the rebase is left in progress */
+func RebaseOnto(onto string, upstream string, branchName string) error {
+	output, err := git.Rebase(rebase.Onto(onto), rebase.Upstream(upstream), rebase.Branch(branchName))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+/* Fetches all branches of a remote, removing the ones deleted there */
+func FetchRemote(remoteName string) error {
+	output, err := git.Fetch(fetch.Prune, fetch.Remote(remoteName))
+	if err != nil {
+		err = errors.New(output)
+	}
+	return err
+}
+
+/* Lists the files changed on head since it diverged from base */
+func ChangedFiles(base string, head string) ([]string, error) {
+	output, err := GitRaw("diff", "--name-only", base+"..."+head, "--")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+/* Returns the line ranges of path on base that were changed or removed on head */
+func ChangedLineRanges(base string, head string, path string) ([][2]int, error) {
+	output, err := GitRaw("diff", "-U0", base+"..."+head, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	hunkRegex := regexp.MustCompile(`(?m)^@@ -(\d+)(?:,(\d+))? `)
+	ranges := [][2]int{}
+	for _, match := range hunkRegex.FindAllStringSubmatch(output, -1) {
+		start, _ := strconv.Atoi(match[1])
+		count := 1
+		if match[2] != "" {
+			count, _ = strconv.Atoi(match[2])
+		}
+		if count > 0 {
+			ranges = append(ranges, [2]int{start, start + count - 1})
+		}
+	}
+	return ranges, nil
+}
+
+/* Returns the author of each line on the given ranges of path at ref */
+func BlameAuthors(ref string, path string, ranges [][2]int) ([]GitAuthor, error) {
+	args := []string{"--line-porcelain"}
+	for _, r := range ranges {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", r[0], r[1]))
+	}
+	output, err := GitRaw("blame", append(args, ref, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	authors := []GitAuthor{}
+	var author GitAuthor
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "author ") {
+			author.Name = strings.TrimPrefix(line, "author ")
+		} else if strings.HasPrefix(line, "author-mail ") {
+			author.Email = strings.Trim(strings.TrimPrefix(line, "author-mail "), "<>")
+			authors = append(authors, author)
+		}
+	}
+	return authors, nil
+}
+
+/* Returns the authors of the last n commits touching path at ref */
+func LogAuthors(ref string, path string, n int) ([]GitAuthor, error) {
+	output, err := GitRaw("log", fmt.Sprintf("-n%d", n), "--no-merges", "--format=%an%x1f%ae", ref, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	authors := []GitAuthor{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if fields := strings.Split(line, "\x1f"); len(fields) == 2 {
+			authors = append(authors, GitAuthor{Name: fields[0], Email: fields[1]})
+		}
+	}
+	return authors, nil
+}
+
+/* Returns the contents of path at ref */
+func ShowFile(ref string, path string) (string, error) {
+	return GitRaw("show", ref+":"+path)
+}
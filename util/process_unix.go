@@ -0,0 +1,13 @@
+//go:build !windows
+
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+/* Starts the process in its own session so it isn't killed when the terminal closes */
+func DetachProcess(process *exec.Cmd) {
+	process.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
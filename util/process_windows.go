@@ -0,0 +1,13 @@
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const detachedProcess = 0x00000008 // DETACHED_PROCESS, not defined in syscall
+
+/* Starts the process without a console so it isn't killed when the terminal closes */
+func DetachProcess(process *exec.Cmd) {
+	process.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
@@ -0,0 +1,318 @@
+package util
+
+import (
+	"bb/api"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type CreatePrOptions struct {
+	Source            string
+	Target            string
+	Title             string
+	EditBody          bool   // write the description in the editor
+	BodyFile          string // read the description from a file, "-" for stdin
+	Fill              bool   // fill the title and description from the commits
+	Reviewers         []string
+	AutoReviewers     bool
+	Draft             bool
+	CloseSource       bool
+	IncludeBranchName bool
+	Yes               bool // no prompts or reviewer selection
+}
+
+/* Creates a pull request from source to target, asking for what the options leave out. Returns an empty pull request when aborted */
+func CreatePullRequest(repo string, options CreatePrOptions) api.PullRequest {
+	scanner := bufio.NewScanner(os.Stdin)
+	if options.BodyFile == "-" && !options.Yes {
+		// the prompts can't be answered once stdin is consumed
+		cobra.CheckErr("--yes is required when reading the description from stdin")
+	}
+
+	// set account id if it doesn't exist
+	authorId := viper.GetString("account_id")
+	if authorId == "" {
+		// TODO make this into an async call that we can retrieve the result later
+		user := api.GetUser()
+		viper.Set("account_id", user.AccountId)
+		// TODO Don't do this because it permanently saves the value from "repo"
+		// and subsequent calls will only use that value
+		// viper.WriteConfig()
+		authorId = user.AccountId
+	}
+
+	// load reviewers
+	candidatesChannel := make(chan []api.User)
+	go func() {
+		defer close(candidatesChannel)
+		candidatesChannel <- LoadReviewerCandidates(repo, authorId)
+	}()
+
+	source, target, title := options.Source, options.Target, options.Title
+	if !ensureBranchPushed(source, scanner, options.Yes) {
+		return api.PullRequest{}
+	}
+	if ahead, err := CountCommits(RemoteOrLocalRef(target), RemoteOrLocalRef(source)); err == nil && ahead == 0 {
+		Printf("\033[1;33mWarning:\033[m '%s' has no commits ahead of '%s'\n", source, target)
+		if options.Yes {
+			cobra.CheckErr("Nothing to merge")
+		}
+		if !AskYesNo(scanner, "Create the PR anyway ?") {
+			return api.PullRequest{}
+		}
+	}
+
+	body := ""
+	if options.Fill {
+		fillTitle, fillBody := describeCommits(source, target)
+		if title == "" {
+			title = fillTitle
+		}
+		body = fillBody
+	} else if options.BodyFile == "" {
+		body = loadTemplate(source, target)
+	}
+	if options.BodyFile != "" {
+		body = ReadBodyFile(options.BodyFile)
+		if options.BodyFile == "-" && title == "" {
+			cobra.CheckErr("A title must be given with --title or --fill when reading the description from stdin")
+		}
+	}
+
+	if title == "" {
+		fmt.Print("? \033[1;35mTitle \033[m")
+		scanner.Scan()
+		title = scanner.Text()
+	}
+	if options.EditBody {
+		body = ReadDescription(body)
+	} else if !options.Fill && options.BodyFile == "" {
+		body = "" // the template is only used when writing the description
+	}
+
+	// select reviewers
+	workspace := strings.Split(repo, "/")[0]
+	candidates := <-candidatesChannel
+	var reviewers []api.User
+	if len(options.Reviewers) > 0 {
+		reviewers = ResolveReviewers(options.Reviewers, candidates, workspace)
+	}
+	if options.AutoReviewers {
+		for _, reviewer := range autoReviewers(suggestReviewers(source, target, candidates, workspace)) {
+			reviewers = AppendUniqueUser(reviewers, reviewer)
+		}
+	} else if len(options.Reviewers) == 0 && !options.Yes {
+		reviewers = chooseReviewers(candidates, suggestReviewers(source, target, candidates, workspace))
+	}
+
+	if options.IncludeBranchName {
+		re := regexp.MustCompile(api.JiraIssueKeyRegex)
+		key := re.FindString(source)
+		title = key + " " + title
+	}
+
+	// create dto
+	newpr := api.CreatePullRequestBody{
+		Title:       title,
+		Description: body,
+		CloseSource: options.CloseSource,
+	}
+	newpr.Source = &api.Branch{}
+	newpr.Source.Branch.Name = source
+	newpr.Destination = &api.Branch{}
+	newpr.Destination.Branch.Name = target
+	newpr.Reviewers = ReviewersBody(reviewers)
+	if options.Draft {
+		newpr.Draft = &options.Draft
+	}
+
+	// confirm pr
+	Printf("%s\033[1;37m%s\033[m  \033[1;34m[ %s → %s ]\033[m\n", FormatPrDraft(newpr.Draft != nil), newpr.Title, newpr.Source.Branch.Name, newpr.Destination.Branch.Name)
+	if newpr.Description != "" {
+		fmt.Printf("%s\n", newpr.Description)
+	}
+	if len(reviewers) > 0 {
+		fmt.Println("Reviewers:")
+		for _, reviewer := range reviewers {
+			fmt.Printf("  - %s \033[37m( ID: %s )\033[m\n", reviewer.DisplayName, reviewer.AccountId)
+		}
+	}
+	if !options.Yes {
+		if !AskYesNo(scanner, "Create this PR ?") {
+			return api.PullRequest{}
+		}
+	}
+
+	// send create request
+	pr := api.PostPr(repo, newpr)
+
+	Printf("\n%s %s\033[1;32m#%d\033[m \033[1;37m%s\033[m\n", FormatPrState(pr.State), FormatPrDraft(pr.Draft), pr.ID, pr.Title)
+	fmt.Printf("\033[37m  opened by %s, %d comments, last updated: %s\033[m\n\n", pr.Author.Nickname, pr.CommentCount, TimeAgo(pr.UpdatedOn))
+	if pr.Description != "" {
+		fmt.Printf("%s\n\n", pr.Description)
+	}
+	return pr
+}
+
+/* Makes sure the source branch exists and is up to date on origin, offering to push it. Returns false to abort */
+func ensureBranchPushed(source string, scanner *bufio.Scanner, assumeYes bool) bool {
+	remoteHash, err := RemoteBranchHash("origin", source)
+	cobra.CheckErr(err)
+	localHash, err := GetRefHash("refs/heads/" + source)
+	if err != nil {
+		// not a local branch so it must exist on the remote
+		if remoteHash == "" {
+			cobra.CheckErr(fmt.Sprintf("Branch '%s' doesn't exist locally or on the remote", source))
+		}
+		return true
+	}
+	if remoteHash == localHash {
+		return true
+	}
+
+	force := false
+	if remoteHash == "" {
+		Printf("Branch \033[1;34m%s\033[m is not on the remote\n", source)
+	} else if IsAncestor(remoteHash, localHash) {
+		Printf("Branch \033[1;34m%s\033[m has commits that are not on the remote\n", source)
+	} else if IsAncestor(localHash, remoteHash) {
+		// nothing to push, the pull request is created with what is on the remote
+		Printf("\033[1;33mWarning:\033[m branch \033[1;34m%s\033[m is behind the remote, the pull request will include the remote commits\n", source)
+		return true
+	} else {
+		Printf("\033[1;33mWarning:\033[m branch \033[1;34m%s\033[m has diverged from the remote\n", source)
+		if assumeYes {
+			cobra.CheckErr(fmt.Sprintf("Branch '%s' has diverged from the remote, push it before creating the pull request", source))
+		}
+		if !AskYesNo(scanner, "Force push it (with lease) ?") {
+			return true // the pull request is created with what is on the remote
+		}
+		force = true
+	}
+	if !force && !assumeYes && !AskYesNo(scanner, "Push it to origin ?") {
+		// the pull request can still be created with what is on the remote
+		return remoteHash != ""
+	}
+	Printf("Pushing \033[1;34m%s\033[m to origin...\n", source)
+	cobra.CheckErr(PushBranch(source, force))
+	return true
+}
+
+// Reads the pull request template from "pr_template" or .bitbucket/PULL_REQUEST_TEMPLATE.md
+// and fills its placeholders {{BRANCH}}, {{TARGET}}, {{JIRA_KEY}} and {{ISSUE_SUMMARY}}
+func loadTemplate(source string, target string) string {
+	path := viper.GetString("pr_template")
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		cobra.CheckErr(err)
+		path = filepath.Join(home, path[2:])
+	} else if path == "" {
+		root, err := GetRepoRoot()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(root, ".bitbucket", "PULL_REQUEST_TEMPLATE.md")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	template := string(content)
+
+	key := regexp.MustCompile(api.JiraIssueKeyRegex).FindString(source)
+	summary := ""
+	if key != "" && viper.IsSet("jira_domain") && strings.Contains(template, "{{ISSUE_SUMMARY}}") {
+		summary = (<-api.FindIssue(key)).Fields.Summary // left empty when the issue can't be found
+	}
+	return strings.NewReplacer(
+		"{{BRANCH}}", source,
+		"{{TARGET}}", target,
+		"{{JIRA_KEY}}", key,
+		"{{ISSUE_SUMMARY}}", summary,
+	).Replace(template)
+}
+
+/* Builds a title and description from the commits in source that are not in target */
+func describeCommits(source string, target string) (string, string) {
+	commits, err := ListCommits(RemoteOrLocalRef(target), source)
+	cobra.CheckErr(err)
+	if len(commits) == 0 {
+		cobra.CheckErr(fmt.Sprintf("No commits between '%s' and '%s' to fill the pull request", target, source))
+	}
+	if len(commits) == 1 {
+		return commits[0].Subject, commits[0].Body
+	}
+	// use the branch name without the issue key, which is added back with --include-branch-name
+	name := regexp.MustCompile(api.JiraIssueKeyRegex).ReplaceAllString(source[strings.LastIndex(source, "/")+1:], "")
+	title := strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(name))
+	body := ""
+	for _, commit := range commits {
+		body += fmt.Sprintf("* %s\n", commit.Subject)
+	}
+	return title, strings.TrimSpace(body)
+}
+
+/* Returns default reviewers followed by the workspace members, without duplicates and without the author */
+func LoadReviewerCandidates(repo string, authorId string) []api.User {
+	membersChannel := api.GetWorkspaceMembers(strings.Split(repo, "/")[0])
+	reviewersChannel := api.GetReviewers(repo)
+
+	seen := map[string]bool{authorId: true}
+	candidates := []api.User{}
+	for _, users := range [][]api.User{<-reviewersChannel, <-membersChannel} {
+		for _, user := range users {
+			if seen[user.AccountId] {
+				continue
+			}
+			seen[user.AccountId] = true
+			candidates = append(candidates, user)
+		}
+	}
+	return candidates
+}
+
+func ReviewersBody(users []api.User) []api.ReviewerBody {
+	body := []api.ReviewerBody{}
+	for _, user := range users {
+		body = append(body, api.ReviewerBody{AccountId: user.AccountId})
+	}
+	return body
+}
+
+func AskYesNo(scanner *bufio.Scanner, question string) bool {
+	fmt.Printf("? \033[1;35m%s [y/n]\033[m ", question)
+	scanner.Scan()
+	return strings.TrimSpace(strings.ToLower(scanner.Text())) == "y"
+}
+
+func ReadDescription(initial string) string {
+	tmpFile, err := os.CreateTemp("/tmp", "bitbucket-pr-body-")
+	cobra.CheckErr(err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(initial)
+	tmpFile.Close()
+	OpenInEditor(tmpFile)
+	description, err := os.ReadFile(tmpFile.Name())
+	cobra.CheckErr(err)
+	return strings.TrimSpace(string(description))
+}
+
+func ReadBodyFile(path string) string {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	cobra.CheckErr(err)
+	return strings.TrimSpace(string(content))
+}
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"bb/api"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+/* Replaces names of groups defined in "reviewer_groups" with their members, keeping any +/- prefix */
+func ExpandReviewerGroups(names []string) []string {
+	groups := viper.GetStringMapStringSlice("reviewer_groups")
+	expanded := []string{}
+	for _, name := range names {
+		prefix, bare := SplitReviewerPrefix(name)
+		if members, ok := groups[strings.ToLower(bare)]; ok {
+			for _, member := range members {
+				expanded = append(expanded, prefix+member)
+			}
+		} else {
+			expanded = append(expanded, name)
+		}
+	}
+	return expanded
+}
+
+func SplitReviewerPrefix(name string) (string, string) {
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		return name[:1], name[1:]
+	}
+	return "", name
+}
+
+/* Finds the single user identified by query (nickname, display name, account id, uuid or email) */
+func ResolveReviewer(query string, candidates []api.User, workspace string) (api.User, error) {
+	q := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if q == "" {
+		return api.User{}, fmt.Errorf("Empty reviewer name")
+	}
+
+	if strings.Contains(q, "@") {
+		found := <-api.GetWorkspaceMembersByEmail(workspace, []string{q})
+		for _, user := range found {
+			for _, c := range candidates {
+				if c.AccountId == user.AccountId {
+					return c, nil
+				}
+			}
+		}
+		return api.User{}, fmt.Errorf("No reviewer found with email '%s'", query)
+	}
+
+	matchers := []func(api.User) bool{
+		// exact identifiers first
+		func(u api.User) bool {
+			return q == strings.ToLower(u.AccountId) || q == strings.ToLower(strings.Trim(u.UUID, "{}")) ||
+				q == strings.ToLower(u.UUID) || q == strings.ToLower(u.Nickname) ||
+				q == strings.ToLower(u.DisplayName) || (u.Username != "" && q == strings.ToLower(u.Username))
+		},
+		// then partial names
+		func(u api.User) bool {
+			return strings.Contains(strings.ToLower(u.Nickname), q) || strings.Contains(strings.ToLower(u.DisplayName), q)
+		},
+		// and finally a fuzzy match on the characters
+		func(u api.User) bool {
+			return isSubsequence(q, strings.ToLower(u.Nickname)) || isSubsequence(q, strings.ToLower(u.DisplayName))
+		},
+	}
+	for _, matches := range matchers {
+		found := []api.User{}
+		for _, c := range candidates {
+			if matches(c) {
+				found = append(found, c)
+			}
+		}
+		if len(found) == 1 {
+			return found[0], nil
+		} else if len(found) > 1 {
+			names := []string{}
+			for _, f := range found {
+				names = append(names, fmt.Sprintf("%s (%s)", f.DisplayName, f.Nickname))
+			}
+			return api.User{}, fmt.Errorf("Reviewer '%s' is ambiguous, it matches: %s", query, strings.Join(names, ", "))
+		}
+	}
+	return api.User{}, fmt.Errorf("No reviewer found matching '%s'", query)
+}
+
+func isSubsequence(needle string, haystack string) bool {
+	i := 0
+	for _, r := range haystack {
+		if i < len(needle) && rune(needle[i]) == r {
+			i++
+		}
+	}
+	return i == len(needle)
+}
+
+/* Resolves names of reviewers or groups of reviewers to users, exiting if any is not found or ambiguous */
+func ResolveReviewers(names []string, candidates []api.User, workspace string) []api.User {
+	resolved := []api.User{}
+	for _, name := range ExpandReviewerGroups(names) {
+		_, bare := SplitReviewerPrefix(name)
+		user, err := ResolveReviewer(bare, candidates, workspace)
+		cobra.CheckErr(err)
+		resolved = AppendUniqueUser(resolved, user)
+	}
+	return resolved
+}
+
+func AppendUniqueUser(users []api.User, user api.User) []api.User {
+	for _, u := range users {
+		if u.AccountId == user.AccountId {
+			return users
+		}
+	}
+	return append(users, user)
+}
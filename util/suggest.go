@@ -0,0 +1,377 @@
+package util
+
+import (
+	"bb/api"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// where bitbucket looks for the code owners file, first one found is used
+var codeownersPaths = []string{"CODEOWNERS", ".bitbucket/CODEOWNERS", "docs/CODEOWNERS"}
+
+const (
+	maxHistoryFiles    = 30 // files of the diff looked up on blame and log
+	historyLogCommits  = 20
+	ownerScore         = 10
+	blameScorePerLines = 10
+	autoHistoryReviews = 2 // reviewers picked from the history besides the code owners
+)
+
+type codeownersRule struct {
+	Pattern *regexp.Regexp
+	Owners  []string
+}
+
+type reviewerSuggestion struct {
+	User    api.User
+	Score   int
+	Owned   int // number of changed files owned
+	Lines   int // changed lines authored
+	Commits int // recent commits on the changed files
+}
+
+/* Ranks candidates to review the changes of source by code ownership and by the history of the changed files */
+func suggestReviewers(source string, target string, candidates []api.User, workspace string) []reviewerSuggestion {
+	targetRef := RemoteOrLocalRef(target)
+	files, err := ChangedFiles(targetRef, source)
+	if err != nil || len(files) == 0 {
+		return []reviewerSuggestion{}
+	}
+
+	suggestions := map[string]*reviewerSuggestion{}
+	suggestion := func(user api.User) *reviewerSuggestion {
+		if _, ok := suggestions[user.AccountId]; !ok {
+			suggestions[user.AccountId] = &reviewerSuggestion{User: user}
+		}
+		return suggestions[user.AccountId]
+	}
+
+	// CODE OWNERS
+
+	rules, groups := loadCodeowners(targetRef)
+	for _, owner := range ownersOf(files, rules, groups) {
+		user, ok := resolveOwner(owner.Name, candidates, workspace)
+		if !ok {
+			continue // owners that are not candidates, like the author
+		}
+		s := suggestion(user)
+		s.Owned += owner.Files
+		s.Score += ownerScore * owner.Files
+	}
+
+	// HISTORY
+
+	if len(files) > maxHistoryFiles {
+		files = files[:maxHistoryFiles]
+	}
+	// the changed line ranges are relative to the merge base, not to the tip of target
+	base, err := MergeBase(targetRef, source)
+	if err != nil {
+		base = targetRef
+	}
+	lines, commits := map[GitAuthor]int{}, map[GitAuthor]int{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, file := range files {
+		wg.Add(1)
+		go func(file string) {
+			defer wg.Done()
+			var blamed, logged []GitAuthor
+			if ranges, err := ChangedLineRanges(targetRef, source, file); err == nil && len(ranges) > 0 {
+				blamed, _ = BlameAuthors(base, file, ranges)
+			}
+			logged, _ = LogAuthors(base, file, historyLogCommits)
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, author := range blamed {
+				lines[author]++
+			}
+			for _, author := range logged {
+				commits[author]++
+			}
+		}(file)
+	}
+	wg.Wait()
+
+	users := matchGitAuthors(append(mapKeys(lines), mapKeys(commits)...), candidates, workspace)
+	for author, user := range users {
+		s := suggestion(user)
+		s.Lines += lines[author]
+		s.Commits += commits[author]
+	}
+	result := []reviewerSuggestion{}
+	for _, s := range suggestions {
+		s.Score += (s.Lines+blameScorePerLines-1)/blameScorePerLines + s.Commits
+		result = append(result, *s)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].User.DisplayName < result[j].User.DisplayName
+	})
+	return result
+}
+
+/* Lets the user pick reviewers, with the suggestions ranked first and the automatic picks preselected */
+func chooseReviewers(reviewers []api.User, suggestions []reviewerSuggestion) []api.User {
+	// the same reviewers as --auto-reviewers are preselected, the other suggestions are only ranked first
+	picked := map[string]bool{}
+	for _, user := range autoReviewers(suggestions) {
+		picked[user.AccountId] = true
+	}
+	ranked := []api.User{}
+	reasons := map[string]string{}
+	preselected := []int{}
+	for i, suggestion := range suggestions {
+		ranked = append(ranked, suggestion.User)
+		reasons[suggestion.User.AccountId] = suggestion.String()
+		if picked[suggestion.User.AccountId] {
+			preselected = append(preselected, i)
+		}
+	}
+	for _, reviewer := range reviewers {
+		ranked = AppendUniqueUser(ranked, reviewer)
+	}
+
+	selected := []api.User{}
+	for _, idx := range SelectFZFPreselected(ranked, "Reviewers > ", func(i int) string {
+		line := fmt.Sprintf("%s \033[37m(%s)\033[m", ranked[i].DisplayName, ranked[i].Nickname)
+		if reason := reasons[ranked[i].AccountId]; reason != "" {
+			line += fmt.Sprintf(" \033[33m%s\033[m", reason)
+		}
+		return line
+	}, preselected) {
+		selected = append(selected, ranked[idx])
+	}
+	return selected
+}
+
+/* Picks the code owners and the top reviewers from the history */
+func autoReviewers(suggestions []reviewerSuggestion) []api.User {
+	picked := []api.User{}
+	fromHistory := 0
+	for _, s := range suggestions {
+		if s.Owned > 0 {
+			picked = append(picked, s.User)
+		} else if fromHistory < autoHistoryReviews {
+			picked = append(picked, s.User)
+			fromHistory++
+		}
+	}
+	return picked
+}
+
+func (s reviewerSuggestion) String() string {
+	reasons := []string{}
+	if s.Owned > 0 {
+		reasons = append(reasons, fmt.Sprintf("owns %d files", s.Owned))
+	}
+	if s.Lines > 0 {
+		reasons = append(reasons, fmt.Sprintf("wrote %d changed lines", s.Lines))
+	}
+	if s.Commits > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d recent commits", s.Commits))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+/* Reads the code owners file from ref, or from the working tree if it's not there. Returns the rules and the groups defined in it */
+func loadCodeowners(ref string) ([]codeownersRule, map[string][]string) {
+	for _, path := range codeownersPaths {
+		if content, err := ShowFile(ref, path); err == nil {
+			return parseCodeowners(content)
+		}
+	}
+	if root, err := GetRepoRoot(); err == nil {
+		for _, path := range codeownersPaths {
+			if content, err := os.ReadFile(filepath.Join(root, path)); err == nil {
+				return parseCodeowners(string(content))
+			}
+		}
+	}
+	return parseCodeowners("")
+}
+
+/* Parses rules "PATTERN OWNER..." and groups "@@@NAME MEMBER...", owners are referenced as @nickname, email or @@group */
+func parseCodeowners(content string) ([]codeownersRule, map[string][]string) {
+	rules := []codeownersRule{}
+	groups := map[string][]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "@@@") {
+			groups[strings.ToLower(strings.TrimPrefix(fields[0], "@@@"))] = fields[1:]
+			continue
+		}
+		rules = append(rules, codeownersRule{Pattern: codeownersRegex(fields[0]), Owners: fields[1:]})
+	}
+	return rules, groups
+}
+
+/* Converts a gitignore style pattern into a regex. Patterns without a slash match at any depth and directories match everything under them */
+func codeownersRegex(pattern string) *regexp.Regexp {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("(?:/.*)?$")
+	return regexp.MustCompile(expr.String())
+}
+
+type fileOwner struct {
+	Name  string
+	Files int
+}
+
+/* Returns the owners of the files with the number of files each one owns. The last matching rule of a file wins */
+func ownersOf(files []string, rules []codeownersRule, groups map[string][]string) []fileOwner {
+	configGroups := viper.GetStringMapStringSlice("reviewer_groups")
+	counts := map[string]int{}
+	order := []string{}
+	for _, file := range files {
+		var owners []string
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(file) {
+				owners = rule.Owners
+			}
+		}
+		seen := map[string]bool{}
+		for _, owner := range owners {
+			names := []string{owner}
+			if strings.HasPrefix(owner, "@@") {
+				group := strings.ToLower(strings.TrimPrefix(owner, "@@"))
+				if members, ok := groups[group]; ok {
+					names = members
+				} else {
+					names = configGroups[group]
+				}
+			}
+			for _, name := range names {
+				name = strings.TrimPrefix(name, "@")
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				if counts[name] == 0 {
+					order = append(order, name)
+				}
+				counts[name]++
+			}
+		}
+	}
+	result := []fileOwner{}
+	for _, name := range order {
+		result = append(result, fileOwner{name, counts[name]})
+	}
+	return result
+}
+
+/* Finds the candidate that is exactly the owner, by nickname, account id or email. Partial names are not matched so an owner is never mistaken for someone else */
+func resolveOwner(owner string, candidates []api.User, workspace string) (api.User, bool) {
+	owner = strings.TrimPrefix(owner, "@")
+	matches := func(user api.User) bool {
+		return strings.EqualFold(user.Nickname, owner) || user.AccountId == owner
+	}
+	if strings.Contains(owner, "@") {
+		users := <-api.GetWorkspaceMembersByEmail(workspace, []string{owner})
+		if len(users) != 1 || users[0].AccountId == "" {
+			return api.User{}, false
+		}
+		matches = func(user api.User) bool { return user.AccountId == users[0].AccountId }
+	}
+	for _, candidate := range candidates {
+		if matches(candidate) {
+			return candidate, true
+		}
+	}
+	return api.User{}, false
+}
+
+/* Finds the candidate of each git author by name, falling back to a lookup by email */
+func matchGitAuthors(authors []GitAuthor, candidates []api.User, workspace string) map[GitAuthor]api.User {
+	result := map[GitAuthor]api.User{}
+	emails := map[string]bool{}
+	for _, author := range authors {
+		for _, candidate := range candidates {
+			if strings.EqualFold(candidate.DisplayName, author.Name) || strings.EqualFold(candidate.Nickname, author.Name) {
+				result[author] = candidate
+				break
+			}
+		}
+		if _, ok := result[author]; !ok && author.Email != "" {
+			emails[strings.ToLower(author.Email)] = true
+		}
+	}
+
+	byEmail := map[string]api.User{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for email := range emails {
+		wg.Add(1)
+		go func(email string) {
+			defer wg.Done()
+			if users := <-api.GetWorkspaceMembersByEmail(workspace, []string{email}); len(users) == 1 {
+				mutex.Lock()
+				byEmail[email] = users[0]
+				mutex.Unlock()
+			}
+		}(email)
+	}
+	wg.Wait()
+
+	for _, author := range authors {
+		user, ok := byEmail[strings.ToLower(author.Email)]
+		if _, matched := result[author]; matched || !ok {
+			continue
+		}
+		// only candidates can be suggested, which also leaves out the author
+		for _, candidate := range candidates {
+			if candidate.AccountId == user.AccountId {
+				result[author] = candidate
+			}
+		}
+	}
+	return result
+}
+
+func mapKeys[K comparable, V any](m map[K]V) []K {
+	keys := []K{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
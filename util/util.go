@@ -6,10 +6,12 @@ import "bb/store"
 
 import (
 	"bb/api"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -70,6 +72,17 @@ func FormatPrState(state api.PrState) string {
 	return FormatSwitchConfig(state.String(), prStatusMap)
 }
 
+func FormatPrDraft(draft bool) string {
+	if !draft {
+		return ""
+	}
+	prStatusMap := make(map[string]ResultSwitchConfig)
+	if err := viper.UnmarshalKey("pr_status", &prStatusMap); err != nil {
+		cobra.CheckErr(err)
+	}
+	return FormatSwitchConfig("DRAFT", prStatusMap) + " "
+}
+
 func FormatPipelineStatus(state string) string {
 	pipelineStatusMap := make(map[string]ResultSwitchConfig)
 	if err := viper.UnmarshalKey("pipeline_status", &pipelineStatusMap); err != nil {
@@ -219,12 +232,27 @@ func SelectFZF[T any](list []T, prompt string, toString func(int) string) []int
 	return indexes
 }
 
-func UseExternalFZF[T any](list []T, prompt string, toString func(int) string) []int {
+/* Same as SelectFZF with the given indexes already selected. Preselection is only supported with fzf */
+func SelectFZFPreselected[T any](list []T, prompt string, toString func(int) string, preselected []int) []int {
+	if len(list) == 0 {
+		return []int{}
+	}
+	if !CommandExists("fzf") || len(preselected) == 0 {
+		return SelectFZF(list, prompt, toString)
+	}
+	actions := []string{}
+	for _, idx := range preselected {
+		actions = append(actions, fmt.Sprintf("pos(%d)+toggle", idx+1))
+	}
+	return UseExternalFZF(list, prompt, toString, "--bind", "start:"+strings.Join(actions, "+")+"+first")
+}
+
+func UseExternalFZF[T any](list []T, prompt string, toString func(int) string, fzfArgs ...string) []int {
 	input := ""
 	for i := range list {
 		input += fmt.Sprintf("%d %s\n", i, toString(i))
 	}
-	cmd := exec.Command("fzf", "-m", "--height", "20%", "--ansi", "--reverse", "--with-nth", "2..", "--prompt", prompt)
+	cmd := exec.Command("fzf", append([]string{"-m", "--height", "20%", "--ansi", "--reverse", "--with-nth", "2..", "--prompt", prompt}, fzfArgs...)...)
 	var selectionBuffer strings.Builder
 	cmd.Stdin = strings.NewReader(input)
 	cmd.Stdout = &selectionBuffer
@@ -245,6 +273,44 @@ func UseExternalFZF[T any](list []T, prompt string, toString func(int) string) [
 	return result
 }
 
+/* Pipes content through pager, $PAGER or less. Content is printed directly if stdout is not a terminal */
+func OpenInPager(content string, pager string) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Print(content)
+		return
+	}
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = "less -FRX"
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	cobra.CheckErr(err)
+}
+
+/* Draws content over the lines drawn on the previous call */
+func Redraw(content string, drawnLines *int) {
+	if *drawnLines > 0 && ColorEnabled() {
+		fmt.Printf("\033[%dA\033[J", *drawnLines)
+	}
+	Printf(strings.ReplaceAll(content, "%", "%%"))
+	*drawnLines = strings.Count(content, "\n")
+}
+
+/* Returns the width of the terminal or 0 if stdout is not one */
+func TerminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
 func CommandExists(cmd string) bool {
 	_, err := exec.LookPath(cmd)
 	return err == nil
@@ -268,11 +334,40 @@ func ReplaceListWithFzf(fzfargs string) {
 	cmd.Run()
 }
 
+// CACHE FUNCTIONS
+
+/* Reads a JSON file from the user cache directory into value, leaving it untouched if the file doesn't exist */
+func ReadCache(name string, value any) {
+	cacheDir, err := os.UserCacheDir()
+	cobra.CheckErr(err)
+	content, err := os.ReadFile(filepath.Join(cacheDir, "bb", name))
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	cobra.CheckErr(err)
+	cobra.CheckErr(json.Unmarshal(content, value))
+}
+
+/* Writes value as a JSON file in the user cache directory */
+func WriteCache(name string, value any) {
+	cacheDir, err := os.UserCacheDir()
+	cobra.CheckErr(err)
+	cobra.CheckErr(os.MkdirAll(filepath.Join(cacheDir, "bb"), 0755))
+	content, err := json.Marshal(value)
+	cobra.CheckErr(err)
+	cobra.CheckErr(os.WriteFile(filepath.Join(cacheDir, "bb", name), content, 0644))
+}
+
 // LOG FUNCTIONS
 
+/* Returns true if ANSI colors should be printed */
+func ColorEnabled() bool {
+	return store.UseColor || term.IsTerminal(int(os.Stdout.Fd()))
+}
+
 /* fmt.Printf wrapper to remove ANSI colors if stdout is not a terminal */
 func Printf(format string, a ...any) {
-	if store.UseColor || term.IsTerminal(int(os.Stdout.Fd())) {
+	if ColorEnabled() {
 		fmt.Printf(format, a...)
 	} else {
 		ansiColorRegex := regexp.MustCompile(`\x1b\[[0-9;]*m`)